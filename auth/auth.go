@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"log/slog"
 	"net/http"
@@ -11,6 +12,14 @@ import (
 
 const SESSION_VALUE_USERID = "userID"
 
+const CONTEXT_USER_ID session.ContentType = "userId"
+
+// UserIDFromContext returns the id of the authenticated user, which is set by EnsureSessionAuthMiddleware.
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(CONTEXT_USER_ID).(int)
+	return userID, ok
+}
+
 func EnsureSessionAuthMiddleware(next http.Handler, sessionRepo *db.SessionRepository) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userIDJson, ok := session.GetSessionValue(sessionRepo, r, SESSION_VALUE_USERID)
@@ -31,6 +40,6 @@ func EnsureSessionAuthMiddleware(next http.Handler, sessionRepo *db.SessionRepos
 		}
 		// TODO: we could validate if the user actually exists. But since we control the string, this is unneeded.
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), CONTEXT_USER_ID, userID)))
 	})
 }
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Category struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type CategoryRepository struct {
+	db DBTX
+}
+
+func NewCategoryRepository(db DBTX) *CategoryRepository {
+	return &CategoryRepository{
+		db: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs all queries in the given transaction.
+func (cr *CategoryRepository) WithTx(tx *sql.Tx) *CategoryRepository {
+	return &CategoryRepository{
+		db: tx,
+	}
+}
+
+func (cr *CategoryRepository) FindAll(ctx context.Context) ([]Category, error) {
+	rows, err := cr.db.QueryContext(ctx, "SELECT id, name FROM categories ORDER BY name ASC;")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find categories %w", err)
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		category := Category{}
+		err := rows.Scan(&category.ID, &category.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find categories %w", err)
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+func (cr *CategoryRepository) FindById(ctx context.Context, id string) (Category, error) {
+	row := cr.db.QueryRowContext(ctx, "SELECT id, name FROM categories WHERE id = ?;", id)
+	category := Category{}
+	err := row.Scan(&category.ID, &category.Name)
+	return category, err
+}
+
+func (cr *CategoryRepository) Create(ctx context.Context, name string) (Category, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Category{}, err
+	}
+	category := Category{
+		ID:   id.String(),
+		Name: name,
+	}
+	_, err = cr.db.ExecContext(ctx, "INSERT INTO categories (id, name) VALUES (?, ?);", category.ID, category.Name)
+	if err != nil {
+		return Category{}, fmt.Errorf("failed to create category: %w", err)
+	}
+	return category, nil
+}
+
+// Delete deletes the category. Items of the category are left without category. Returns sql.ErrNoRows if the
+// category does not exist.
+func (cr *CategoryRepository) Delete(ctx context.Context, id string) error {
+	result, err := cr.db.ExecContext(ctx, "DELETE FROM categories WHERE id = ?;", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// FindAssignment returns the category last assigned to an item with the given text, or nil if there is none.
+func (cr *CategoryRepository) FindAssignment(ctx context.Context, text string) (*string, error) {
+	row := cr.db.QueryRowContext(ctx, "SELECT category FROM category_assignments WHERE text = ?;", text)
+	var category string
+	err := row.Scan(&category)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find category assignment: %w", err)
+	}
+	return &category, nil
+}
+
+// SaveAssignment remembers that the category was assigned to an item with the given text.
+func (cr *CategoryRepository) SaveAssignment(ctx context.Context, text string, category string) error {
+	_, err := cr.db.ExecContext(ctx, "INSERT INTO category_assignments (text, category, date) VALUES (?, ?, ?) ON CONFLICT (text) DO UPDATE SET category = excluded.category, date = excluded.date;", text, category, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to save category assignment: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type StoredEvent struct {
+	Seq     int64
+	Type    string
+	ListID  *string
+	Payload json.RawMessage
+	Date    string
+}
+
+type EventRepository struct {
+	db *sql.DB
+}
+
+func NewEventRepository(db *sql.DB) *EventRepository {
+	return &EventRepository{
+		db: db,
+	}
+}
+
+// Create appends an event to the event log, and returns the sequence number assigned to it.
+func (er *EventRepository) Create(ctx context.Context, eventType string, listID *string, payload json.RawMessage) (int64, error) {
+	row := er.db.QueryRowContext(ctx, "INSERT INTO events (type, list, payload, date) VALUES (?, ?, ?, ?) RETURNING seq", eventType, listID, string(payload), time.Now().Format(time.RFC3339))
+
+	var seq int64
+	err := row.Scan(&seq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to store event %w", err)
+	}
+	return seq, nil
+}
+
+// FindAllSince returns at most limit events with a sequence number greater than seq, in order.
+func (er *EventRepository) FindAllSince(ctx context.Context, seq int64, limit int) ([]StoredEvent, error) {
+	rows, err := er.db.QueryContext(ctx, "SELECT seq, type, list, payload, date FROM events WHERE seq > ? ORDER BY seq ASC LIMIT ?;", seq, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find events %w", err)
+	}
+	defer rows.Close()
+
+	storedEvents := []StoredEvent{}
+	for rows.Next() {
+		event := StoredEvent{}
+		var payload string
+		err := rows.Scan(&event.Seq, &event.Type, &event.ListID, &payload, &event.Date)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find events %w", err)
+		}
+		event.Payload = json.RawMessage(payload)
+		storedEvents = append(storedEvents, event)
+	}
+	return storedEvents, rows.Err()
+}
+
+// FindCursor returns the sequence number of the last event the consumer with the given name processed, or false if
+// it did not process any event yet.
+func (er *EventRepository) FindCursor(ctx context.Context, name string) (int64, bool, error) {
+	row := er.db.QueryRowContext(ctx, "SELECT seq FROM event_cursors WHERE name = ?;", name)
+
+	var seq int64
+	err := row.Scan(&seq)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to find event cursor %w", err)
+	}
+	return seq, true, nil
+}
+
+// SaveCursor stores that the consumer with the given name processed all events up to seq. The cursor never moves
+// backwards, so that multiple server instances can share it.
+func (er *EventRepository) SaveCursor(ctx context.Context, name string, seq int64) error {
+	_, err := er.db.ExecContext(ctx, "INSERT INTO event_cursors (name, seq) VALUES (?, ?) ON CONFLICT (name) DO UPDATE SET seq = max(seq, excluded.seq);", name, seq)
+	if err != nil {
+		return fmt.Errorf("failed to save event cursor %w", err)
+	}
+	return nil
+}
+
+// FindSeqBounds returns the lowest and highest sequence number still stored in the event log. If the event log is
+// empty, both are 0.
+func (er *EventRepository) FindSeqBounds(ctx context.Context) (int64, int64, error) {
+	row := er.db.QueryRowContext(ctx, "SELECT COALESCE(MIN(seq), 0), COALESCE(MAX(seq), 0) FROM events;")
+
+	var oldest, latest int64
+	err := row.Scan(&oldest, &latest)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to find event log bounds %w", err)
+	}
+	return oldest, latest, nil
+}
+
+// DeleteOlderThan removes all events from the event log that were stored before the given time. The most recent event
+// is always kept, so that the latest sequence number can still be determined.
+func (er *EventRepository) DeleteOlderThan(ctx context.Context, before time.Time) error {
+	_, err := er.db.ExecContext(ctx, "DELETE FROM events WHERE date < ? AND seq < (SELECT MAX(seq) FROM events);", before.Format(time.RFC3339))
+	return err
+}
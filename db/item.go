@@ -5,42 +5,61 @@ import (
 	"context"
 	"database/sql"
 	"encoding/binary"
+	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 )
 
 type ShoppingListItem struct {
-	ID            string  `json:"id"`
-	Text          string  `json:"text"`
-	Checked       bool    `json:"checked"`
+	ID      string `json:"id"`
+	Text    string `json:"text"`
+	Checked bool   `json:"checked"`
+	// Quantity and Unit are optional; an item with a Quantity but without an Unit is counted in pieces
+	Quantity *float64 `json:"quantity"`
+	Unit     *string  `json:"unit"`
+	// Note is an optional remark, e.g. which groups duplicates were merged from
+	Note *string `json:"note"`
+	// Category is the id of the category of the item, if any
+	Category      *string `json:"category"`
 	Parent        *string `json:"parent"`
 	List          string  `json:"list"`
 	Sort          float64 `json:"sort"`
 	SortFractions []int   `json:"-"`
+	// Version is incremented on every change of the item
+	Version int `json:"version"`
 }
 
 type ItemRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewItemRepository(db *sql.DB) *ItemRepository {
+func NewItemRepository(db DBTX) *ItemRepository {
 	return &ItemRepository{
 		db: db,
 	}
 }
 
+// WithTx returns a copy of the repository that runs all queries in the given transaction.
+func (ir *ItemRepository) WithTx(tx *sql.Tx) *ItemRepository {
+	return &ItemRepository{
+		db: tx,
+	}
+}
+
 func (ir *ItemRepository) FindAllByListId(ctx context.Context, listId string) ([]ShoppingListItem, error) {
-	rows, err := ir.db.QueryContext(ctx, "SELECT id, text, checked, parent, sort, sortFractions, list FROM items WHERE list = ? ORDER BY sort ASC;", listId)
+	rows, err := ir.db.QueryContext(ctx, "SELECT id, text, checked, quantity, unit, note, category, parent, sort, sortFractions, list, version FROM items WHERE list = ? ORDER BY sort ASC;", listId)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	items := []ShoppingListItem{}
 	for rows.Next() {
 		item := ShoppingListItem{}
 		var rawSortFractions []byte
 
-		err = rows.Scan(&item.ID, &item.Text, &item.Checked, &item.Parent, &item.Sort, &rawSortFractions, &item.List)
+		err = rows.Scan(&item.ID, &item.Text, &item.Checked, &item.Quantity, &item.Unit, &item.Note, &item.Category, &item.Parent, &item.Sort, &rawSortFractions, &item.List, &item.Version)
 		if err != nil {
 			return nil, err
 		}
@@ -57,11 +76,11 @@ func (ir *ItemRepository) FindAllByListId(ctx context.Context, listId string) ([
 }
 
 func (ir *ItemRepository) FindByID(ctx context.Context, itemId string) (ShoppingListItem, error) {
-	row := ir.db.QueryRowContext(ctx, "SELECT id, text, checked, parent, sort, sortFractions, list FROM items WHERE id = ? LIMIT 1;", itemId)
+	row := ir.db.QueryRowContext(ctx, "SELECT id, text, checked, quantity, unit, note, category, parent, sort, sortFractions, list, version FROM items WHERE id = ? LIMIT 1;", itemId)
 
 	item := ShoppingListItem{}
 	var rawSortFractions []byte
-	err := row.Scan(&item.ID, &item.Text, &item.Checked, &item.Parent, &item.Sort, &rawSortFractions, &item.List)
+	err := row.Scan(&item.ID, &item.Text, &item.Checked, &item.Quantity, &item.Unit, &item.Note, &item.Category, &item.Parent, &item.Sort, &rawSortFractions, &item.List, &item.Version)
 	if err != nil {
 		return ShoppingListItem{}, err
 	}
@@ -75,7 +94,7 @@ func (ir *ItemRepository) FindByID(ctx context.Context, itemId string) (Shopping
 	return item, nil
 }
 
-func (ir *ItemRepository) Create(ctx context.Context, listId string, text string, sortFractions [2]int) (string, error) {
+func (ir *ItemRepository) Create(ctx context.Context, listId string, text string, quantity *float64, unit *string, category *string, sortFractions [2]int) (string, error) {
 	id, err := uuid.NewV7()
 	if err != nil {
 		return "", err
@@ -86,18 +105,30 @@ func (ir *ItemRepository) Create(ctx context.Context, listId string, text string
 	binary.Write(buf, binary.LittleEndian, uint32(sortFractions[0]))
 	binary.Write(buf, binary.LittleEndian, uint32(sortFractions[1]))
 
-	_, err = ir.db.ExecContext(ctx, "INSERT INTO items (id, text, checked, parent, sort, sortFractions, list) VALUES (?, ?, ?, ?, ?, ?, ?)", id, text, false, nil, sort, buf.Bytes(), listId)
+	_, err = ir.db.ExecContext(ctx, "INSERT INTO items (id, text, normalizedText, checked, quantity, unit, category, parent, sort, sortFractions, list) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", id, text, NormalizeText(text), false, quantity, unit, category, nil, sort, buf.Bytes(), listId)
 
 	return id.String(), err
 }
 
-func (ir *ItemRepository) UpdateChecked(ctx context.Context, itemId string, checked bool) error {
-	_, err := ir.db.ExecContext(ctx, "UPDATE items SET checked=? WHERE id = ?;", checked, itemId)
-	return err
+// Copy creates an unchecked copy of the item in the given list, below parentId, keeping its position among its
+// siblings.
+func (ir *ItemRepository) Copy(ctx context.Context, item ShoppingListItem, listId string, parentId *string) (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, uint32(item.SortFractions[0]))
+	binary.Write(buf, binary.LittleEndian, uint32(item.SortFractions[1]))
+
+	_, err = ir.db.ExecContext(ctx, "INSERT INTO items (id, text, normalizedText, checked, quantity, unit, note, category, parent, sort, sortFractions, list) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", id, item.Text, NormalizeText(item.Text), false, item.Quantity, item.Unit, item.Note, item.Category, parentId, item.Sort, buf.Bytes(), listId)
+
+	return id.String(), err
 }
 
-func (ir *ItemRepository) UpdateText(ctx context.Context, itemId string, text string) error {
-	_, err := ir.db.ExecContext(ctx, "UPDATE items SET text=? WHERE id = ?;", text, itemId)
+func (ir *ItemRepository) Update(ctx context.Context, itemId string, text string, checked bool, quantity *float64, unit *string, note *string, category *string) error {
+	_, err := ir.db.ExecContext(ctx, "UPDATE items SET text=?, normalizedText=?, checked=?, quantity=?, unit=?, note=?, category=?, version=version+1 WHERE id = ?;", text, NormalizeText(text), checked, quantity, unit, note, category, itemId)
 	return err
 }
 
@@ -107,7 +138,19 @@ func (ir *ItemRepository) Move(ctx context.Context, itemId string, parentId *str
 	binary.Write(buf, binary.LittleEndian, uint32(sortFractions[0]))
 	binary.Write(buf, binary.LittleEndian, uint32(sortFractions[1]))
 
-	_, err := ir.db.ExecContext(ctx, "UPDATE items SET parent=?, sort=?, sortFractions=? WHERE id=?;", parentId, sort, buf.Bytes(), itemId)
+	_, err := ir.db.ExecContext(ctx, "UPDATE items SET parent=?, sort=?, sortFractions=?, version=version+1 WHERE id=?;", parentId, sort, buf.Bytes(), itemId)
+	return err
+}
+
+// SetSortFractions changes the position of the item among its siblings without incrementing its version, as
+// renormalising keeps the order of the items, and therefore does not count as change.
+func (ir *ItemRepository) SetSortFractions(ctx context.Context, itemId string, sortFractions []int) error {
+	sort := float64(sortFractions[0]) / float64(sortFractions[1])
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, uint32(sortFractions[0]))
+	binary.Write(buf, binary.LittleEndian, uint32(sortFractions[1]))
+
+	_, err := ir.db.ExecContext(ctx, "UPDATE items SET sort=?, sortFractions=? WHERE id=?;", sort, buf.Bytes(), itemId)
 	return err
 }
 
@@ -115,3 +158,177 @@ func (ir *ItemRepository) Delete(ctx context.Context, itemID string) error {
 	_, err := ir.db.ExecContext(ctx, "DELETE FROM items WHERE id = ?", itemID)
 	return err
 }
+
+// ItemUsage is an item of any list together with the date of its list, used to analyse which items are bought.
+type ItemUsage struct {
+	Text     string
+	Checked  bool
+	Quantity *float64
+	Unit     *string
+	Category *string
+	List     string
+	// ListDate is the time the list of the item was created
+	ListDate string
+}
+
+// FindPurchases returns the items that were checked, i.e. bought, on at least minLists lists, except for items whose
+// normalized text is on the list excludedListId. An item is only returned once per list, with the details it was last
+// added with on that list. Purchases are ordered by the date of their list and their order in the list.
+func (ir *ItemRepository) FindPurchases(ctx context.Context, excludedListId string, minLists int) ([]ItemUsage, error) {
+	rows, err := ir.db.QueryContext(ctx, `
+		WITH purchases AS (
+			SELECT items.normalizedText, items.text, items.quantity, items.unit, items.category, items.sort, lists.id AS list, lists.date,
+				ROW_NUMBER() OVER (PARTITION BY items.normalizedText, lists.id ORDER BY items.sort DESC) AS n
+			FROM items JOIN lists ON lists.id = items.list
+			WHERE items.checked AND items.normalizedText != '' AND lists.id != ?1
+				AND items.normalizedText NOT IN (SELECT normalizedText FROM items WHERE list = ?1 AND normalizedText IS NOT NULL)
+		)
+		SELECT text, quantity, unit, category, list, date FROM purchases
+		WHERE n = 1 AND normalizedText IN (SELECT normalizedText FROM purchases WHERE n = 1 GROUP BY normalizedText HAVING COUNT(*) >= ?2)
+		ORDER BY date ASC, list ASC, sort ASC;`, excludedListId, minLists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find item purchases: %w", err)
+	}
+	defer rows.Close()
+
+	purchases := []ItemUsage{}
+	for rows.Next() {
+		purchase := ItemUsage{Checked: true}
+		err := rows.Scan(&purchase.Text, &purchase.Quantity, &purchase.Unit, &purchase.Category, &purchase.List, &purchase.ListDate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find item purchases: %w", err)
+		}
+		purchases = append(purchases, purchase)
+	}
+	return purchases, rows.Err()
+}
+
+// NormalizeText returns the text used to compare items, ignoring case and whitespace. Unlike lower in sqlite, it
+// lowercases all letters, not only ASCII ones.
+func NormalizeText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// FillNormalizedTexts sets the normalized text of all items that have none yet, e.g. because they were created before
+// it was stored.
+func (ir *ItemRepository) FillNormalizedTexts(ctx context.Context) error {
+	rows, err := ir.db.QueryContext(ctx, "SELECT id, text FROM items WHERE normalizedText IS NULL;")
+	if err != nil {
+		return fmt.Errorf("failed to find items without normalized text: %w", err)
+	}
+	texts := map[string]string{}
+	for rows.Next() {
+		var id, text string
+		err := rows.Scan(&id, &text)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to find items without normalized text: %w", err)
+		}
+		texts[id] = text
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to find items without normalized text: %w", err)
+	}
+
+	for id, text := range texts {
+		_, err := ir.db.ExecContext(ctx, "UPDATE items SET normalizedText = ? WHERE id = ?;", NormalizeText(text), id)
+		if err != nil {
+			return fmt.Errorf("failed to fill normalized text of item %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// FindSuggestionCandidates returns up to limit normalized texts of items that contain all of the normalized words,
+// preferring items used on many and recent lists. The words are only matched as substrings, so the caller has to check
+// whether they match as intended.
+func (ir *ItemRepository) FindSuggestionCandidates(ctx context.Context, words []string, limit int) ([]string, error) {
+	query := "SELECT items.normalizedText AS normalized FROM items JOIN lists ON lists.id = items.list WHERE normalized != ''"
+	args := []any{}
+	for _, word := range words {
+		query += " AND normalized LIKE '%' || ? || '%' ESCAPE '\\'"
+		args = append(args, escapeLike(word))
+	}
+	query += " GROUP BY normalized ORDER BY COUNT(DISTINCT items.list) DESC, MAX(lists.date) DESC LIMIT ?;"
+	args = append(args, limit)
+
+	rows, err := ir.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find suggestion candidates: %w", err)
+	}
+	defer rows.Close()
+
+	texts := []string{}
+	for rows.Next() {
+		var text string
+		err := rows.Scan(&text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find suggestion candidates: %w", err)
+		}
+		texts = append(texts, text)
+	}
+	return texts, rows.Err()
+}
+
+// FindUsagesByNormalizedText returns the items of all lists whose normalized text is one of texts, ordered by the date
+// of their list and their order in the list.
+func (ir *ItemRepository) FindUsagesByNormalizedText(ctx context.Context, texts []string) ([]ItemUsage, error) {
+	if len(texts) == 0 {
+		return []ItemUsage{}, nil
+	}
+	args := make([]any, 0, len(texts))
+	for _, text := range texts {
+		args = append(args, text)
+	}
+	placeholders := strings.Repeat("?, ", len(texts)-1) + "?"
+	rows, err := ir.db.QueryContext(ctx, "SELECT items.text, items.checked, items.quantity, items.unit, items.category, lists.id, lists.date FROM items JOIN lists ON lists.id = items.list WHERE items.normalizedText IN ("+placeholders+") ORDER BY lists.date ASC, items.sort ASC;", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find item usages: %w", err)
+	}
+	defer rows.Close()
+
+	usages := []ItemUsage{}
+	for rows.Next() {
+		usage := ItemUsage{}
+		err := rows.Scan(&usage.Text, &usage.Checked, &usage.Quantity, &usage.Unit, &usage.Category, &usage.List, &usage.ListDate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find item usages: %w", err)
+		}
+		usages = append(usages, usage)
+	}
+	return usages, rows.Err()
+}
+
+// escapeLike escapes the wildcards of LIKE, using backslash as escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(s)
+}
+
+// Search returns up to limit items of all lists whose text matches the FTS5 query, best matches first.
+func (ir *ItemRepository) Search(ctx context.Context, query string, limit int) ([]ShoppingListItem, error) {
+	rows, err := ir.db.QueryContext(ctx, "SELECT items.id, items.text, items.checked, items.quantity, items.unit, items.note, items.category, items.parent, items.sort, items.sortFractions, items.list, items.version FROM items_search JOIN items ON items.id = items_search.id WHERE items_search MATCH ? ORDER BY items_search.rank LIMIT ?;", query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []ShoppingListItem{}
+	for rows.Next() {
+		item := ShoppingListItem{}
+		var rawSortFractions []byte
+
+		err = rows.Scan(&item.ID, &item.Text, &item.Checked, &item.Quantity, &item.Unit, &item.Note, &item.Category, &item.Parent, &item.Sort, &rawSortFractions, &item.List, &item.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search items: %w", err)
+		}
+		if (len(rawSortFractions)) > 0 {
+			item.SortFractions = []int{
+				int(binary.LittleEndian.Uint32(rawSortFractions[0:4])),
+				int(binary.LittleEndian.Uint32(rawSortFractions[4:8])),
+			}
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
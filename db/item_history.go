@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ItemHistoryEntry is a change of an item. OldValue and NewValue contain the item as JSON before and after the change;
+// OldValue is nil for created items, and NewValue is nil for deleted items.
+type ItemHistoryEntry struct {
+	Seq       int64  `json:"seq"`
+	Item      string `json:"item"`
+	List      string `json:"list"`
+	Operation string `json:"operation"`
+	// User is the id of the user who made the change, or nil if it was made by the server
+	User     *int            `json:"user"`
+	Username *string         `json:"username"`
+	Date     string          `json:"date"`
+	OldValue json.RawMessage `json:"oldValue"`
+	NewValue json.RawMessage `json:"newValue"`
+}
+
+type ItemHistoryRepository struct {
+	db DBTX
+}
+
+func NewItemHistoryRepository(db DBTX) *ItemHistoryRepository {
+	return &ItemHistoryRepository{
+		db: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs all queries in the given transaction.
+func (hr *ItemHistoryRepository) WithTx(tx *sql.Tx) *ItemHistoryRepository {
+	return &ItemHistoryRepository{
+		db: tx,
+	}
+}
+
+func encodeHistoryValue(item *ShoppingListItem) (*string, error) {
+	if item == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode item for history: %w", err)
+	}
+	value := string(encoded)
+	return &value, nil
+}
+
+// Create records a change of an item. oldValue and newValue are nil if the item did not exist before or after the
+// change.
+func (hr *ItemHistoryRepository) Create(ctx context.Context, itemId string, listId string, operation string, userId *int, oldValue *ShoppingListItem, newValue *ShoppingListItem) error {
+	rawOldValue, err := encodeHistoryValue(oldValue)
+	if err != nil {
+		return err
+	}
+	rawNewValue, err := encodeHistoryValue(newValue)
+	if err != nil {
+		return err
+	}
+
+	_, err = hr.db.ExecContext(ctx, "INSERT INTO item_history (item, list, operation, user, date, oldValue, newValue) VALUES (?, ?, ?, ?, ?, ?, ?);", itemId, listId, operation, userId, time.Now().Format(time.RFC3339), rawOldValue, rawNewValue)
+	if err != nil {
+		return fmt.Errorf("failed to record item history: %w", err)
+	}
+	return nil
+}
+
+// FindAllByListId returns at most limit changes of items of the list, newest first.
+func (hr *ItemHistoryRepository) FindAllByListId(ctx context.Context, listId string, limit int) ([]ItemHistoryEntry, error) {
+	return hr.find(ctx, "item_history.list = ?", listId, limit)
+}
+
+// FindAllByItemId returns at most limit changes of the item, newest first.
+func (hr *ItemHistoryRepository) FindAllByItemId(ctx context.Context, itemId string, limit int) ([]ItemHistoryEntry, error) {
+	return hr.find(ctx, "item_history.item = ?", itemId, limit)
+}
+
+func (hr *ItemHistoryRepository) find(ctx context.Context, condition string, arg string, limit int) ([]ItemHistoryEntry, error) {
+	rows, err := hr.db.QueryContext(ctx, "SELECT item_history.seq, item_history.item, item_history.list, item_history.operation, item_history.user, users.username, item_history.date, item_history.oldValue, item_history.newValue FROM item_history LEFT JOIN users ON users.id = item_history.user WHERE "+condition+" ORDER BY item_history.seq DESC LIMIT ?;", arg, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find item history %w", err)
+	}
+	defer rows.Close()
+
+	entries := []ItemHistoryEntry{}
+	for rows.Next() {
+		entry := ItemHistoryEntry{}
+		var oldValue, newValue *string
+		err := rows.Scan(&entry.Seq, &entry.Item, &entry.List, &entry.Operation, &entry.User, &entry.Username, &entry.Date, &oldValue, &newValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find item history %w", err)
+		}
+		if oldValue != nil {
+			entry.OldValue = json.RawMessage(*oldValue)
+		}
+		if newValue != nil {
+			entry.NewValue = json.RawMessage(*newValue)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,171 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openTestDB opens an in-memory database with the columns of lists and items the queries under test need.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dbConn, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection would get its own in-memory database
+	dbConn.SetMaxOpenConns(1)
+	t.Cleanup(func() { dbConn.Close() })
+
+	_, err = dbConn.Exec(`
+		CREATE TABLE lists (id text PRIMARY KEY NOT NULL, date text NOT NULL);
+		CREATE TABLE items (
+			id text PRIMARY KEY NOT NULL, text text NOT NULL, normalizedText text, checked integer NOT NULL,
+			quantity real, unit text, note text, category text, list text NOT NULL, parent text, sort real NOT NULL,
+			sortFractions blob NOT NULL, version integer NOT NULL DEFAULT 1
+		);
+		INSERT INTO lists (id, date) VALUES ('list', '2026-03-01T10:00:00Z');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dbConn
+}
+
+func TestFindSuggestionCandidates(t *testing.T) {
+	ctx := context.Background()
+	ir := NewItemRepository(openTestDB(t))
+	for i, text := range []string{"Äpfel", " Olive  Öl ", "apple juice"} {
+		_, err := ir.Create(ctx, "list", text, nil, nil, nil, [2]int{i + 1, 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		words []string
+		want  []string
+	}{
+		{name: "non-ASCII capital", words: []string{"äp"}, want: []string{"äpfel"}},
+		{name: "non-ASCII capital after whitespace", words: []string{"öl"}, want: []string{"olive öl"}},
+		{name: "several words", words: []string{"ol", "öl"}, want: []string{"olive öl"}},
+		{name: "ASCII", words: []string{"ap"}, want: []string{"apple juice"}},
+		{name: "wildcards are escaped", words: []string{"a%"}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ir.FindSuggestionCandidates(ctx, tt.words, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("FindSuggestionCandidates() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillNormalizedTexts(t *testing.T) {
+	ctx := context.Background()
+	dbConn := openTestDB(t)
+	_, err := dbConn.Exec("INSERT INTO items (id, text, checked, list, sort, sortFractions) VALUES ('item', ' Äpfel ', 0, 'list', 1, x'0100000001000000');")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ir := NewItemRepository(dbConn)
+	err = ir.FillNormalizedTexts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usages, err := ir.FindUsagesByNormalizedText(ctx, []string{"äpfel"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usages) != 1 || usages[0].Text != " Äpfel " {
+		t.Errorf("FindUsagesByNormalizedText() = %+v, want the item", usages)
+	}
+}
+
+func TestCopyUnchecksItem(t *testing.T) {
+	ctx := context.Background()
+	ir := NewItemRepository(openTestDB(t))
+	item := ShoppingListItem{Text: "milk", Checked: true, Quantity: ptr(2.0), Sort: 1, SortFractions: []int{1, 1}}
+
+	id, err := ir.Copy(ctx, item, "list", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := ir.FindByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied.Checked {
+		t.Errorf("copied item is checked")
+	}
+	if copied.Text != "milk" || copied.Quantity == nil || *copied.Quantity != 2 {
+		t.Errorf("copied item = %+v, want text and quantity of the item", copied)
+	}
+}
+
+func TestFindPurchases(t *testing.T) {
+	ctx := context.Background()
+	dbConn := openTestDB(t)
+	_, err := dbConn.Exec(`INSERT INTO lists (id, date) VALUES
+		('a', '2026-01-01T10:00:00Z'), ('b', '2026-01-08T10:00:00Z'), ('c', '2026-01-15T10:00:00Z');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ir := NewItemRepository(dbConn)
+	for i, item := range []struct {
+		list     string
+		text     string
+		checked  bool
+		quantity *float64
+	}{
+		{list: "a", text: "Milk", checked: true},
+		{list: "a", text: "milk", checked: true, quantity: ptr(2.0)},
+		{list: "b", text: "milk", checked: true},
+		{list: "a", text: "eggs", checked: true},
+		{list: "b", text: "eggs", checked: true},
+		{list: "c", text: "eggs"},
+		{list: "a", text: "bread", checked: true},
+		{list: "b", text: "bread", checked: true},
+		{list: "list", text: "Bread"},
+		{list: "c", text: "butter", checked: true},
+	} {
+		id, err := ir.Create(ctx, item.list, item.text, item.quantity, nil, nil, [2]int{i + 1, 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ir.Update(ctx, id, item.text, item.checked, item.quantity, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	purchases, err := ir.FindPurchases(ctx, "list", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, purchase := range purchases {
+		got = append(got, purchase.List+":"+purchase.Text)
+	}
+	// bread is on the list already, butter was bought once, eggs were not checked on c
+	want := []string{"a:milk", "a:eggs", "b:milk", "b:eggs"}
+	if !slices.Equal(got, want) {
+		t.Errorf("FindPurchases() = %q, want %q", got, want)
+	}
+	if quantity := purchases[0].Quantity; quantity == nil || *quantity != 2 {
+		t.Errorf("quantity of milk on a = %v, want the one last added", quantity)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -12,28 +12,48 @@ import (
 type ShoppingList struct {
 	ID     string `json:"id"`
 	Status string `json:"status"`
-	Date   string `json:"date"`
+	// Date is the time the list was created
+	Date  string  `json:"date"`
+	Title string  `json:"title"`
+	Notes *string `json:"notes"`
+	// PlannedDate is the day the shopping trip is planned for, formatted as YYYY-MM-DD
+	PlannedDate *string `json:"plannedDate"`
+	// Store is the id of the store layout of the store the list is for
+	Store *string `json:"store"`
+	// ArchivedAt is the time the list was archived, or nil if it is not archived
+	ArchivedAt *string `json:"archivedAt"`
+	// Version is incremented on every change of the list
+	Version int `json:"version"`
 }
 
 type ListRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewListRepository(db *sql.DB) *ListRepository {
+func NewListRepository(db DBTX) *ListRepository {
 	return &ListRepository{
 		db: db,
 	}
 }
 
-func (lr *ListRepository) FindAll(ctx context.Context) ([]ShoppingList, error) {
-	rows, err := lr.db.QueryContext(ctx, "SELECT id, status, date FROM lists ORDER BY date DESC;")
+// WithTx returns a copy of the repository that runs all queries in the given transaction.
+func (lr *ListRepository) WithTx(tx *sql.Tx) *ListRepository {
+	return &ListRepository{
+		db: tx,
+	}
+}
+
+// FindAll returns all lists. If archived is set, only archived or only not archived lists are returned.
+func (lr *ListRepository) FindAll(ctx context.Context, archived *bool) ([]ShoppingList, error) {
+	rows, err := lr.db.QueryContext(ctx, "SELECT id, status, date, title, notes, plannedDate, store, archivedAt, version FROM lists WHERE ? IS NULL OR (archivedAt IS NOT NULL) = ? ORDER BY date DESC;", archived, archived)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find list %w", err)
 	}
+	defer rows.Close()
 	listItems := []ShoppingList{}
 	for rows.Next() {
 		list := ShoppingList{}
-		err := rows.Scan(&list.ID, &list.Status, &list.Date)
+		err := rows.Scan(&list.ID, &list.Status, &list.Date, &list.Title, &list.Notes, &list.PlannedDate, &list.Store, &list.ArchivedAt, &list.Version)
 		if err != nil {
 			return nil, fmt.Errorf("failed to find list %w", err)
 		}
@@ -44,31 +64,83 @@ func (lr *ListRepository) FindAll(ctx context.Context) ([]ShoppingList, error) {
 }
 
 func (lr *ListRepository) FindById(ctx context.Context, id string) (ShoppingList, error) {
-	row := lr.db.QueryRowContext(ctx, "SELECT id, status, date FROM lists WHERE id = ?;", id)
+	row := lr.db.QueryRowContext(ctx, "SELECT id, status, date, title, notes, plannedDate, store, archivedAt, version FROM lists WHERE id = ?;", id)
 	list := ShoppingList{}
-	err := row.Scan(&list.ID, &list.Status, &list.Date)
+	err := row.Scan(&list.ID, &list.Status, &list.Date, &list.Title, &list.Notes, &list.PlannedDate, &list.Store, &list.ArchivedAt, &list.Version)
 	if err != nil {
 		return ShoppingList{}, fmt.Errorf("failed to find list with id %s %w", id, err)
 	}
 	return list, nil
 }
 
-func (lr *ListRepository) Create(ctx context.Context) (ShoppingList, error) {
+func (lr *ListRepository) Create(ctx context.Context, status string, title string, notes *string, plannedDate *string, store *string) (ShoppingList, error) {
 	id, err := uuid.NewV7()
 	if err != nil {
 		return ShoppingList{}, err
 	}
-	row := lr.db.QueryRowContext(ctx, "INSERT into lists (id, status, date) VALUES (?, ?, ?) RETURNING id, status, date", id, "todo", time.Now().Format(time.RFC3339))
+	row := lr.db.QueryRowContext(ctx, "INSERT into lists (id, status, date, title, notes, plannedDate, store) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id, status, date, title, notes, plannedDate, store, archivedAt, version", id, status, time.Now().Format(time.RFC3339), title, notes, plannedDate, store)
 
 	list := ShoppingList{}
-	err = row.Scan(&list.ID, &list.Status, &list.Date)
+	err = row.Scan(&list.ID, &list.Status, &list.Date, &list.Title, &list.Notes, &list.PlannedDate, &list.Store, &list.ArchivedAt, &list.Version)
 	if err != nil {
 		return ShoppingList{}, fmt.Errorf("failed to find list with id %s %w", id, err)
 	}
 	return list, nil
 }
 
-func (lr *ListRepository) UpdateStatus(ctx context.Context, id string, newStatus string) error {
-	_, err := lr.db.ExecContext(ctx, "UPDATE lists SET status=? WHERE id=?", newStatus, id)
+func (lr *ListRepository) Update(ctx context.Context, id string, status string, title string, notes *string, plannedDate *string, store *string, archivedAt *string) error {
+	_, err := lr.db.ExecContext(ctx, "UPDATE lists SET status=?, title=?, notes=?, plannedDate=?, store=?, archivedAt=?, version=version+1 WHERE id=?", status, title, notes, plannedDate, store, archivedAt, id)
 	return err
 }
+
+// FindArchivedBefore returns the ids of all lists that were archived before the given time.
+func (lr *ListRepository) FindArchivedBefore(ctx context.Context, before time.Time) ([]string, error) {
+	rows, err := lr.db.QueryContext(ctx, "SELECT id FROM lists WHERE archivedAt < ?;", before.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find archived lists %w", err)
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find archived lists %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Delete deletes the list and all of its items. It should be called in a transaction.
+func (lr *ListRepository) Delete(ctx context.Context, id string) error {
+	_, err := lr.db.ExecContext(ctx, "DELETE FROM items WHERE list = ?;", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete items of list: %w", err)
+	}
+	_, err = lr.db.ExecContext(ctx, "DELETE FROM lists WHERE id = ?;", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete list: %w", err)
+	}
+	return nil
+}
+
+// Search returns up to limit lists whose title or notes match the FTS5 query, best matches first.
+func (lr *ListRepository) Search(ctx context.Context, query string, limit int) ([]ShoppingList, error) {
+	rows, err := lr.db.QueryContext(ctx, "SELECT lists.id, lists.status, lists.date, lists.title, lists.notes, lists.plannedDate, lists.store, lists.archivedAt, lists.version FROM lists_search JOIN lists ON lists.id = lists_search.id WHERE lists_search MATCH ? ORDER BY lists_search.rank LIMIT ?;", query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search lists: %w", err)
+	}
+	defer rows.Close()
+
+	lists := []ShoppingList{}
+	for rows.Next() {
+		list := ShoppingList{}
+		err := rows.Scan(&list.ID, &list.Status, &list.Date, &list.Title, &list.Notes, &list.PlannedDate, &list.Store, &list.ArchivedAt, &list.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search lists: %w", err)
+		}
+		lists = append(lists, list)
+	}
+	return lists, rows.Err()
+}
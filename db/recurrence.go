@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Recurrence creates lists on a schedule.
+type Recurrence struct {
+	ID string `json:"id"`
+	// Title is the title of the created lists
+	Title string `json:"title"`
+	// Frequency is either weekly, biweekly or monthly
+	Frequency string `json:"frequency"`
+	// Start is the time of the first run, formatted as RFC3339; later runs are counted from it
+	Start   string `json:"start"`
+	NextRun string `json:"nextRun"`
+	// Template is the id of the template the lists are created from. If it is nil, lists are created from the
+	// unchecked items of the last list created by the recurrence.
+	Template *string `json:"template"`
+	// LastList is the id of the list created by the last run, if it still exists
+	LastList *string `json:"lastList"`
+}
+
+type RecurrenceRepository struct {
+	db DBTX
+}
+
+func NewRecurrenceRepository(db DBTX) *RecurrenceRepository {
+	return &RecurrenceRepository{
+		db: db,
+	}
+}
+
+func (rr *RecurrenceRepository) FindAll(ctx context.Context) ([]Recurrence, error) {
+	rows, err := rr.db.QueryContext(ctx, "SELECT id, title, frequency, start, nextRun, template, lastList FROM recurrences ORDER BY id ASC;")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find recurrences %w", err)
+	}
+	defer rows.Close()
+
+	recurrences := []Recurrence{}
+	for rows.Next() {
+		recurrence := Recurrence{}
+		err := rows.Scan(&recurrence.ID, &recurrence.Title, &recurrence.Frequency, &recurrence.Start, &recurrence.NextRun, &recurrence.Template, &recurrence.LastList)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find recurrences %w", err)
+		}
+		recurrences = append(recurrences, recurrence)
+	}
+	return recurrences, rows.Err()
+}
+
+func (rr *RecurrenceRepository) FindById(ctx context.Context, id string) (Recurrence, error) {
+	row := rr.db.QueryRowContext(ctx, "SELECT id, title, frequency, start, nextRun, template, lastList FROM recurrences WHERE id = ?;", id)
+	recurrence := Recurrence{}
+	err := row.Scan(&recurrence.ID, &recurrence.Title, &recurrence.Frequency, &recurrence.Start, &recurrence.NextRun, &recurrence.Template, &recurrence.LastList)
+	return recurrence, err
+}
+
+func (rr *RecurrenceRepository) Create(ctx context.Context, title string, frequency string, start string, nextRun string, template *string) (Recurrence, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Recurrence{}, err
+	}
+	_, err = rr.db.ExecContext(ctx, "INSERT INTO recurrences (id, title, frequency, start, nextRun, template) VALUES (?, ?, ?, ?, ?, ?);", id.String(), title, frequency, start, nextRun, template)
+	if err != nil {
+		return Recurrence{}, fmt.Errorf("failed to create recurrence: %w", err)
+	}
+	return rr.FindById(ctx, id.String())
+}
+
+// ClaimRun moves the next run of the recurrence from nextRun to the given time. It returns false if the next run is
+// no longer nextRun, i.e. the run was already claimed by another server instance.
+func (rr *RecurrenceRepository) ClaimRun(ctx context.Context, id string, nextRun string, newNextRun string) (bool, error) {
+	result, err := rr.db.ExecContext(ctx, "UPDATE recurrences SET nextRun = ? WHERE id = ? AND nextRun = ?;", newNextRun, id, nextRun)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim run of recurrence: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim run of recurrence: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// SetLastList remembers the list created by the last run of the recurrence.
+func (rr *RecurrenceRepository) SetLastList(ctx context.Context, id string, listId string) error {
+	_, err := rr.db.ExecContext(ctx, "UPDATE recurrences SET lastList = ? WHERE id = ?;", listId, id)
+	if err != nil {
+		return fmt.Errorf("failed to update recurrence: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes the recurrence. Lists it created are kept. Returns sql.ErrNoRows if the recurrence does not exist.
+func (rr *RecurrenceRepository) Delete(ctx context.Context, id string) error {
+	result, err := rr.db.ExecContext(ctx, "DELETE FROM recurrences WHERE id = ?;", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete recurrence: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete recurrence: %w", err)
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -16,6 +16,23 @@ import (
 const META_FILE = "0000_meta.sql"
 const SCHEMA_TABLE_NAME = "schema"
 
+// ErrFTS5Unavailable is returned by EnsureFTS5 if sqlite was built without the FTS5 extension, which the search needs.
+var ErrFTS5Unavailable = errors.New("sqlite was built without FTS5; build with -tags sqlite_fts5")
+
+// EnsureFTS5 checks that sqlite supports FTS5, so that a binary built without the build tag sqlite_fts5 fails with a
+// clear error instead of failing to migrate the database.
+func EnsureFTS5(ctx context.Context, dbConn *sql.DB) error {
+	var enabled bool
+	err := dbConn.QueryRowContext(ctx, "SELECT sqlite_compileoption_used('ENABLE_FTS5');").Scan(&enabled)
+	if err != nil {
+		return fmt.Errorf("failed to check for FTS5: %w", err)
+	}
+	if !enabled {
+		return ErrFTS5Unavailable
+	}
+	return nil
+}
+
 func EnsureUpToDateSchema(schemaFs embed.FS, dbConn *sql.DB, ctx context.Context) error {
 	schemaDir, err := fs.Sub(schemaFs, "schema")
 	if err != nil {
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// StoreLayout describes the order in which the categories are found in a store.
+type StoreLayout struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Categories contains the ids of the categories, in the order they are found in the store
+	Categories []string `json:"categories"`
+}
+
+type StoreLayoutRepository struct {
+	db DBTX
+}
+
+func NewStoreLayoutRepository(db DBTX) *StoreLayoutRepository {
+	return &StoreLayoutRepository{
+		db: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs all queries in the given transaction.
+func (sr *StoreLayoutRepository) WithTx(tx *sql.Tx) *StoreLayoutRepository {
+	return &StoreLayoutRepository{
+		db: tx,
+	}
+}
+
+func (sr *StoreLayoutRepository) FindAll(ctx context.Context) ([]StoreLayout, error) {
+	rows, err := sr.db.QueryContext(ctx, "SELECT id, name FROM store_layouts ORDER BY name ASC;")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find store layouts %w", err)
+	}
+	defer rows.Close()
+
+	layouts := []StoreLayout{}
+	for rows.Next() {
+		layout := StoreLayout{}
+		err := rows.Scan(&layout.ID, &layout.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find store layouts %w", err)
+		}
+		layouts = append(layouts, layout)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find store layouts %w", err)
+	}
+
+	for i := range layouts {
+		layouts[i].Categories, err = sr.findCategories(ctx, layouts[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return layouts, nil
+}
+
+func (sr *StoreLayoutRepository) FindById(ctx context.Context, id string) (StoreLayout, error) {
+	row := sr.db.QueryRowContext(ctx, "SELECT id, name FROM store_layouts WHERE id = ?;", id)
+	layout := StoreLayout{}
+	err := row.Scan(&layout.ID, &layout.Name)
+	if err != nil {
+		return StoreLayout{}, err
+	}
+	layout.Categories, err = sr.findCategories(ctx, id)
+	if err != nil {
+		return StoreLayout{}, err
+	}
+	return layout, nil
+}
+
+func (sr *StoreLayoutRepository) findCategories(ctx context.Context, layoutId string) ([]string, error) {
+	rows, err := sr.db.QueryContext(ctx, "SELECT category FROM store_layout_categories WHERE layout = ? ORDER BY position ASC;", layoutId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find categories of store layout %w", err)
+	}
+	defer rows.Close()
+
+	categories := []string{}
+	for rows.Next() {
+		var category string
+		err := rows.Scan(&category)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find categories of store layout %w", err)
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+// Create creates a store layout. It should be called in a transaction, as the categories are stored separately.
+func (sr *StoreLayoutRepository) Create(ctx context.Context, name string, categories []string) (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+	_, err = sr.db.ExecContext(ctx, "INSERT INTO store_layouts (id, name) VALUES (?, ?);", id.String(), name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create store layout: %w", err)
+	}
+	err = sr.saveCategories(ctx, id.String(), categories)
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// Update changes the name and the categories of the store layout. It should be called in a transaction, as the
+// categories are stored separately.
+func (sr *StoreLayoutRepository) Update(ctx context.Context, id string, name string, categories []string) error {
+	_, err := sr.db.ExecContext(ctx, "UPDATE store_layouts SET name = ? WHERE id = ?;", name, id)
+	if err != nil {
+		return fmt.Errorf("failed to update store layout: %w", err)
+	}
+	_, err = sr.db.ExecContext(ctx, "DELETE FROM store_layout_categories WHERE layout = ?;", id)
+	if err != nil {
+		return fmt.Errorf("failed to update store layout: %w", err)
+	}
+	return sr.saveCategories(ctx, id, categories)
+}
+
+func (sr *StoreLayoutRepository) saveCategories(ctx context.Context, layoutId string, categories []string) error {
+	for position, category := range categories {
+		_, err := sr.db.ExecContext(ctx, "INSERT INTO store_layout_categories (layout, category, position) VALUES (?, ?, ?);", layoutId, category, position)
+		if err != nil {
+			return fmt.Errorf("failed to save categories of store layout: %w", err)
+		}
+	}
+	return nil
+}
+
+// Delete deletes the store layout. Returns sql.ErrNoRows if the store layout does not exist.
+func (sr *StoreLayoutRepository) Delete(ctx context.Context, id string) error {
+	result, err := sr.db.ExecContext(ctx, "DELETE FROM store_layouts WHERE id = ?;", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete store layout: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete store layout: %w", err)
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
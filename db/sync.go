@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const SyncOperationApplied = "applied"
+const SyncOperationConflict = "conflict"
+const SyncOperationFailed = "failed"
+
+// SyncOperation is an operation a client made while offline, and synced afterwards.
+type SyncOperation struct {
+	// DeviceID identifies the device that made the operation; client ids are only unique per device
+	DeviceID string `json:"-"`
+	ClientID string `json:"clientId"`
+	Type     string `json:"type"`
+	Status   string `json:"status"`
+	// ClientItemID is the temporary id the client used for an item it created while offline
+	ClientItemID *string `json:"clientItemId,omitempty"`
+	// ItemID is the id of the item created by the operation
+	ItemID          *string `json:"itemId,omitempty"`
+	Error           *string `json:"error,omitempty"`
+	ClientTimestamp string  `json:"-"`
+	Date            string  `json:"-"`
+}
+
+type SyncOperationRepository struct {
+	db DBTX
+}
+
+func NewSyncOperationRepository(db DBTX) *SyncOperationRepository {
+	return &SyncOperationRepository{
+		db: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs all queries in the given transaction.
+func (sr *SyncOperationRepository) WithTx(tx *sql.Tx) *SyncOperationRepository {
+	return &SyncOperationRepository{
+		db: tx,
+	}
+}
+
+// FindByClientId returns the operation of the device with the given client id, or false if it was not synced yet.
+func (sr *SyncOperationRepository) FindByClientId(ctx context.Context, deviceID string, clientID string) (SyncOperation, bool, error) {
+	row := sr.db.QueryRowContext(ctx, "SELECT deviceId, clientId, type, status, clientItemId, itemId, error, clientTimestamp, date FROM sync_operations WHERE deviceId = ? AND clientId = ?", deviceID, clientID)
+
+	op := SyncOperation{}
+	err := row.Scan(&op.DeviceID, &op.ClientID, &op.Type, &op.Status, &op.ClientItemID, &op.ItemID, &op.Error, &op.ClientTimestamp, &op.Date)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SyncOperation{}, false, nil
+	}
+	if err != nil {
+		return SyncOperation{}, false, fmt.Errorf("failed to find sync operation %w", err)
+	}
+	return op, true, nil
+}
+
+// FindItemIdByClientItemId returns the id of the item the device created offline using the temporary clientItemID.
+func (sr *SyncOperationRepository) FindItemIdByClientItemId(ctx context.Context, deviceID string, clientItemID string) (string, bool, error) {
+	row := sr.db.QueryRowContext(ctx, "SELECT itemId FROM sync_operations WHERE deviceId = ? AND clientItemId = ? AND itemId IS NOT NULL ORDER BY date DESC LIMIT 1", deviceID, clientItemID)
+
+	var itemID string
+	err := row.Scan(&itemID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to find item id by client item id %w", err)
+	}
+	return itemID, true, nil
+}
+
+// Create stores the result of the operation, unless an operation of the device with the same client id exists
+// already. It returns false if the operation was stored before.
+func (sr *SyncOperationRepository) Create(ctx context.Context, op SyncOperation) (bool, error) {
+	res, err := sr.db.ExecContext(ctx, "INSERT INTO sync_operations (deviceId, clientId, type, status, clientItemId, itemId, error, clientTimestamp, date) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (deviceId, clientId) DO NOTHING", op.DeviceID, op.ClientID, op.Type, op.Status, op.ClientItemID, op.ItemID, op.Error, op.ClientTimestamp, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return false, fmt.Errorf("failed to create sync operation %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to create sync operation %w", err)
+	}
+	return affected == 1, nil
+}
@@ -0,0 +1,198 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Template is a reusable tree of items, e.g. weekly staples, that lists can be created from.
+type Template struct {
+	ID    string         `json:"id"`
+	Name  string         `json:"name"`
+	Items []TemplateItem `json:"items"`
+}
+
+// TemplateItem is an item of a template. Unlike items of lists, template items are never checked, and contain their
+// children in the order they are shown.
+type TemplateItem struct {
+	ID       string         `json:"id"`
+	Text     string         `json:"text"`
+	Quantity *float64       `json:"quantity"`
+	Unit     *string        `json:"unit"`
+	Note     *string        `json:"note"`
+	Category *string        `json:"category"`
+	Children []TemplateItem `json:"children"`
+}
+
+type TemplateRepository struct {
+	db DBTX
+}
+
+func NewTemplateRepository(db DBTX) *TemplateRepository {
+	return &TemplateRepository{
+		db: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs all queries in the given transaction.
+func (tr *TemplateRepository) WithTx(tx *sql.Tx) *TemplateRepository {
+	return &TemplateRepository{
+		db: tx,
+	}
+}
+
+func (tr *TemplateRepository) FindAll(ctx context.Context) ([]Template, error) {
+	rows, err := tr.db.QueryContext(ctx, "SELECT id, name FROM templates ORDER BY name ASC;")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find templates %w", err)
+	}
+	defer rows.Close()
+
+	templates := []Template{}
+	for rows.Next() {
+		template := Template{}
+		err := rows.Scan(&template.ID, &template.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find templates %w", err)
+		}
+		templates = append(templates, template)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find templates %w", err)
+	}
+
+	for i := range templates {
+		templates[i].Items, err = tr.findItems(ctx, templates[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+func (tr *TemplateRepository) FindById(ctx context.Context, id string) (Template, error) {
+	row := tr.db.QueryRowContext(ctx, "SELECT id, name FROM templates WHERE id = ?;", id)
+	template := Template{}
+	err := row.Scan(&template.ID, &template.Name)
+	if err != nil {
+		return Template{}, err
+	}
+	template.Items, err = tr.findItems(ctx, id)
+	if err != nil {
+		return Template{}, err
+	}
+	return template, nil
+}
+
+// findItems returns the tree of items of the template.
+func (tr *TemplateRepository) findItems(ctx context.Context, templateId string) ([]TemplateItem, error) {
+	rows, err := tr.db.QueryContext(ctx, "SELECT id, parent, text, quantity, unit, note, category FROM template_items WHERE template = ? ORDER BY position ASC;", templateId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find items of template %w", err)
+	}
+	defer rows.Close()
+
+	childrenByParent := map[string][]TemplateItem{}
+	for rows.Next() {
+		item := TemplateItem{}
+		var parent *string
+		err := rows.Scan(&item.ID, &parent, &item.Text, &item.Quantity, &item.Unit, &item.Note, &item.Category)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find items of template %w", err)
+		}
+		// top level items are stored with the empty string as key
+		key := ""
+		if parent != nil {
+			key = *parent
+		}
+		childrenByParent[key] = append(childrenByParent[key], item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find items of template %w", err)
+	}
+
+	var build func(parent string) []TemplateItem
+	build = func(parent string) []TemplateItem {
+		items := childrenByParent[parent]
+		for i := range items {
+			items[i].Children = build(items[i].ID)
+		}
+		if items == nil {
+			items = []TemplateItem{}
+		}
+		return items
+	}
+	return build(""), nil
+}
+
+// Create creates a template with the given tree of items; the ids of the items are ignored. It should be called in a
+// transaction, as the items are stored separately.
+func (tr *TemplateRepository) Create(ctx context.Context, name string, items []TemplateItem) (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+	_, err = tr.db.ExecContext(ctx, "INSERT INTO templates (id, name) VALUES (?, ?);", id.String(), name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create template: %w", err)
+	}
+	err = tr.saveItems(ctx, id.String(), nil, items)
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// Update replaces the name and the items of the template. It should be called in a transaction, as the items are
+// stored separately.
+func (tr *TemplateRepository) Update(ctx context.Context, id string, name string, items []TemplateItem) error {
+	_, err := tr.db.ExecContext(ctx, "UPDATE templates SET name = ? WHERE id = ?;", name, id)
+	if err != nil {
+		return fmt.Errorf("failed to update template: %w", err)
+	}
+	_, err = tr.db.ExecContext(ctx, "DELETE FROM template_items WHERE template = ?;", id)
+	if err != nil {
+		return fmt.Errorf("failed to update template: %w", err)
+	}
+	return tr.saveItems(ctx, id, nil, items)
+}
+
+func (tr *TemplateRepository) saveItems(ctx context.Context, templateId string, parentId *string, items []TemplateItem) error {
+	for position, item := range items {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return err
+		}
+		itemId := id.String()
+		_, err = tr.db.ExecContext(ctx, "INSERT INTO template_items (id, template, parent, position, text, quantity, unit, note, category) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);", itemId, templateId, parentId, position, item.Text, item.Quantity, item.Unit, item.Note, item.Category)
+		if err != nil {
+			return fmt.Errorf("failed to save items of template: %w", err)
+		}
+		err = tr.saveItems(ctx, templateId, &itemId, item.Children)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete deletes the template with its items. Returns sql.ErrNoRows if the template does not exist.
+func (tr *TemplateRepository) Delete(ctx context.Context, id string) error {
+	result, err := tr.db.ExecContext(ctx, "DELETE FROM templates WHERE id = ?;", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
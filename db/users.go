@@ -29,6 +29,14 @@ func (ur *UserRepository) FindByUsername(ctx context.Context, username string) (
 	return user, err
 }
 
+func (ur *UserRepository) FindById(ctx context.Context, id int) (User, error) {
+	row := ur.db.QueryRowContext(ctx, "SELECT id, username, passwordHash FROM users WHERE id = ?", id)
+
+	user := User{}
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash)
+	return user, err
+}
+
 func (ur *UserRepository) Create(ctx context.Context, username string, hash string) (User, error) {
 	row := ur.db.QueryRowContext(ctx, "INSERT INTO users (username, passwordHash) VALUES (?, ?) RETURNING id, username, passwordHash", username, hash)
 
@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Webhook struct {
+	ID     string
+	URL    string
+	Secret string
+	// EventTypes contains the event types the webhook is triggered for. If empty, it is triggered for all events.
+	EventTypes []string
+	CreatedAt  string
+}
+
+const WebhookDeliveryPending = "pending"
+const WebhookDeliverySucceeded = "succeeded"
+const WebhookDeliveryFailed = "failed"
+
+type WebhookDelivery struct {
+	ID             int
+	Webhook        string
+	EventSeq       int64
+	Attempt        int
+	Status         string
+	ResponseStatus *int
+	Error          *string
+	Date           string
+}
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{
+		db: db,
+	}
+}
+
+func (wr *WebhookRepository) FindAll(ctx context.Context) ([]Webhook, error) {
+	rows, err := wr.db.QueryContext(ctx, "SELECT id, url, secret, eventTypes, createdAt FROM webhooks ORDER BY createdAt ASC;")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find webhooks %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		webhook := Webhook{}
+		var eventTypes string
+		err := rows.Scan(&webhook.ID, &webhook.URL, &webhook.Secret, &eventTypes, &webhook.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find webhooks %w", err)
+		}
+		err = json.Unmarshal([]byte(eventTypes), &webhook.EventTypes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse event types of webhook %s %w", webhook.ID, err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (wr *WebhookRepository) Create(ctx context.Context, url string, secret string, eventTypes []string) (Webhook, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Webhook{}, err
+	}
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	rawEventTypes, err := json.Marshal(eventTypes)
+	if err != nil {
+		return Webhook{}, err
+	}
+	createdAt := time.Now().Format(time.RFC3339)
+
+	_, err = wr.db.ExecContext(ctx, "INSERT INTO webhooks (id, url, secret, eventTypes, createdAt) VALUES (?, ?, ?, ?, ?)", id.String(), url, secret, string(rawEventTypes), createdAt)
+	if err != nil {
+		return Webhook{}, fmt.Errorf("failed to create webhook %w", err)
+	}
+	return Webhook{
+		ID:         id.String(),
+		URL:        url,
+		Secret:     secret,
+		EventTypes: eventTypes,
+		CreatedAt:  createdAt,
+	}, nil
+}
+
+// Delete removes the webhook and its deliveries. It returns sql.ErrNoRows if no webhook with the id exists.
+func (wr *WebhookRepository) Delete(ctx context.Context, id string) error {
+	result, err := wr.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ClaimDelivery records that an attempt to deliver the event to the webhook is started. It returns false if the
+// attempt was already claimed, e.g. by another server instance.
+func (wr *WebhookRepository) ClaimDelivery(ctx context.Context, webhookID string, eventSeq int64, attempt int) (int, bool, error) {
+	row := wr.db.QueryRowContext(ctx, "INSERT INTO webhook_deliveries (webhook, eventSeq, attempt, status, date) VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING RETURNING id", webhookID, eventSeq, attempt, WebhookDeliveryPending, time.Now().Format(time.RFC3339))
+
+	var id int
+	err := row.Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to claim webhook delivery %w", err)
+	}
+	return id, true, nil
+}
+
+func (wr *WebhookRepository) FinishDelivery(ctx context.Context, id int, status string, responseStatus *int, deliveryErr *string) error {
+	_, err := wr.db.ExecContext(ctx, "UPDATE webhook_deliveries SET status = ?, responseStatus = ?, error = ?, date = ? WHERE id = ?", status, responseStatus, deliveryErr, time.Now().Format(time.RFC3339), id)
+	return err
+}
+
+// FindUnfinishedDeliveries returns the last attempt of every delivery that has to be continued: attempts that failed,
+// if fewer than maxAttempts were made, and attempts that are still pending since before staleBefore, as the server
+// making them was stopped.
+func (wr *WebhookRepository) FindUnfinishedDeliveries(ctx context.Context, maxAttempts int, staleBefore time.Time) ([]WebhookDelivery, error) {
+	rows, err := wr.db.QueryContext(ctx, "SELECT id, webhook, eventSeq, attempt, status, responseStatus, error, date FROM webhook_deliveries AS d WHERE attempt = (SELECT MAX(attempt) FROM webhook_deliveries WHERE webhook = d.webhook AND eventSeq = d.eventSeq) AND ((status = ? AND attempt < ?) OR (status = ? AND date < ?)) ORDER BY eventSeq ASC;", WebhookDeliveryFailed, maxAttempts, WebhookDeliveryPending, staleBefore.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find unfinished webhook deliveries %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		delivery := WebhookDelivery{}
+		err := rows.Scan(&delivery.ID, &delivery.Webhook, &delivery.EventSeq, &delivery.Attempt, &delivery.Status, &delivery.ResponseStatus, &delivery.Error, &delivery.Date)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find unfinished webhook deliveries %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// FindDeliveries returns the most recent delivery attempts of the webhook, newest first.
+func (wr *WebhookRepository) FindDeliveries(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error) {
+	rows, err := wr.db.QueryContext(ctx, "SELECT id, webhook, eventSeq, attempt, status, responseStatus, error, date FROM webhook_deliveries WHERE webhook = ? ORDER BY id DESC LIMIT ?;", webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find webhook deliveries %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		delivery := WebhookDelivery{}
+		err := rows.Scan(&delivery.ID, &delivery.Webhook, &delivery.EventSeq, &delivery.Attempt, &delivery.Status, &delivery.ResponseStatus, &delivery.Error, &delivery.Date)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find webhook deliveries %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
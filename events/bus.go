@@ -0,0 +1,89 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/craftamap/shopping-list/db"
+)
+
+// EventBus distributes published events to the subscribers of all server instances sharing the event bus.
+type EventBus interface {
+	Publish(event Event) error
+}
+
+var _ EventBus = &EventHub{}
+var _ EventBus = &SQLiteEventBus{}
+
+// SQLiteEventBus only stores published events in the event log. Every server instance polls the event log for new
+// events, and delivers them to the subscribers of its EventHub. This allows multiple instances sharing the same
+// database to stay in sync.
+type SQLiteEventBus struct {
+	hub          *EventHub
+	eventRepo    *db.EventRepository
+	pollInterval time.Duration
+}
+
+func NewSQLiteEventBus(hub *EventHub, eventRepo *db.EventRepository, pollInterval time.Duration) *SQLiteEventBus {
+	return &SQLiteEventBus{
+		hub:          hub,
+		eventRepo:    eventRepo,
+		pollInterval: pollInterval,
+	}
+}
+
+func (sb *SQLiteEventBus) Publish(event Event) error {
+	start := time.Now()
+	_, err := sb.hub.store(event)
+	if err != nil {
+		return err
+	}
+	sb.hub.metrics.observePublish(time.Since(start))
+	return nil
+}
+
+// Run polls the event log for new events until ctx is done. Only events stored after Run was started are delivered.
+func (sb *SQLiteEventBus) Run(ctx context.Context) error {
+	_, lastSeq, err := sb.eventRepo.FindSeqBounds(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get latest sequence number: %w", err)
+	}
+
+	ticker := time.NewTicker(sb.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			lastSeq, err = sb.poll(ctx, lastSeq)
+			if err != nil {
+				slog.Error("failed to poll event log", "err", err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// poll delivers all events after lastSeq, and returns the sequence number of the last delivered event.
+func (sb *SQLiteEventBus) poll(ctx context.Context, lastSeq int64) (int64, error) {
+	for {
+		storedEvents, err := sb.eventRepo.FindAllSince(ctx, lastSeq, maxReplayEvents)
+		if err != nil {
+			return lastSeq, err
+		}
+		for _, storedEvent := range storedEvents {
+			lastSeq = storedEvent.Seq
+			msg, err := messageFromStoredEvent(storedEvent)
+			if err != nil {
+				slog.Error("skipping invalid event in event log", "seq", storedEvent.Seq, "err", err)
+				continue
+			}
+			sb.hub.deliver(msg)
+		}
+		if len(storedEvents) < maxReplayEvents {
+			return lastSeq, nil
+		}
+	}
+}
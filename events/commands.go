@@ -0,0 +1,42 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+)
+
+const EventTypeAck EventType = "ACK"
+
+// Command is a mutation sent by a client over the websocket connection, e.g. to create an item.
+type Command struct {
+	Type    string
+	Payload json.RawMessage
+}
+
+// CommandHandler executes commands sent by clients. The returned result is sent back to the client in the
+// acknowledgement.
+type CommandHandler interface {
+	HandleCommand(ctx context.Context, command Command) (any, error)
+}
+
+// Ack acknowledges a command sent by a client. If the command failed, OK is false and Error contains the reason.
+type Ack struct {
+	Type      EventType `json:"type"`
+	RequestID string    `json:"requestID"`
+	OK        bool      `json:"ok"`
+	Result    any       `json:"result,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func NewAck(requestID string, result any, err error) Ack {
+	ack := Ack{
+		Type:      EventTypeAck,
+		RequestID: requestID,
+		OK:        err == nil,
+		Result:    result,
+	}
+	if err != nil {
+		ack.Error = err.Error()
+	}
+	return ack
+}
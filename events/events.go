@@ -1,24 +1,31 @@
 package events
 
+import "github.com/craftamap/shopping-list/db"
+
 type EventType string
 
 type Event interface {
 	GetType() EventType
+	GetListID() string
 }
 
 const EventTypeListCreated EventType = "LIST_CREATED"
 const EventTypeListUpdated EventType = "LIST_UPDATED"
+const EventTypeListDeleted EventType = "LIST_DELETED"
 const EventTypeItemsInListChanged EventType = "ITEMS_IN_LIST_CHANGED"
 
+// ListCreatedEvent and ListUpdatedEvent contain the list as it is after the change.
 type ListCreatedEvent struct {
-	Type   EventType `json:"type"`
-	ListID string    `json:"listID"`
+	Type   EventType       `json:"type"`
+	ListID string          `json:"listID"`
+	List   db.ShoppingList `json:"list"`
 }
 
-func NewListCreatedEvent(listID string) ListCreatedEvent {
+func NewListCreatedEvent(list db.ShoppingList) ListCreatedEvent {
 	return ListCreatedEvent{
 		Type:   EventTypeListCreated,
-		ListID: listID,
+		ListID: list.ID,
+		List:   list,
 	}
 }
 
@@ -26,15 +33,21 @@ func (lce ListCreatedEvent) GetType() EventType {
 	return EventTypeListCreated
 }
 
+func (lce ListCreatedEvent) GetListID() string {
+	return lce.ListID
+}
+
 type ListUpdatedEvent struct {
-	Type   EventType `json:"type"`
-	ListID string    `json:"listID"`
+	Type   EventType       `json:"type"`
+	ListID string          `json:"listID"`
+	List   db.ShoppingList `json:"list"`
 }
 
-func NewListUpdatedEvent(listID string) ListUpdatedEvent {
+func NewListUpdatedEvent(list db.ShoppingList) ListUpdatedEvent {
 	return ListUpdatedEvent{
-		Type:   EventTypeListCreated,
-		ListID: listID,
+		Type:   EventTypeListUpdated,
+		ListID: list.ID,
+		List:   list,
 	}
 }
 
@@ -42,6 +55,30 @@ func (lue ListUpdatedEvent) GetType() EventType {
 	return EventTypeListUpdated
 }
 
+func (lue ListUpdatedEvent) GetListID() string {
+	return lue.ListID
+}
+
+type ListDeletedEvent struct {
+	Type   EventType `json:"type"`
+	ListID string    `json:"listID"`
+}
+
+func NewListDeletedEvent(listID string) ListDeletedEvent {
+	return ListDeletedEvent{
+		Type:   EventTypeListDeleted,
+		ListID: listID,
+	}
+}
+
+func (lde ListDeletedEvent) GetType() EventType {
+	return EventTypeListDeleted
+}
+
+func (lde ListDeletedEvent) GetListID() string {
+	return lde.ListID
+}
+
 type ItemsInListChangedEvent struct {
 	Type   EventType `json:"type"`
 	ListID string    `json:"listID"`
@@ -57,3 +94,116 @@ func NewItemsInListChangedEvent(listID string) ItemsInListChangedEvent {
 func (lue ItemsInListChangedEvent) GetType() EventType {
 	return EventTypeItemsInListChanged
 }
+
+func (lue ItemsInListChangedEvent) GetListID() string {
+	return lue.ListID
+}
+
+const EventTypeItemCreated EventType = "ITEM_CREATED"
+const EventTypeItemUpdated EventType = "ITEM_UPDATED"
+const EventTypeItemMoved EventType = "ITEM_MOVED"
+const EventTypeItemDeleted EventType = "ITEM_DELETED"
+
+// ItemEvent is sent for every change to a single item, carrying the state of the item after the change (or before
+// deletion). Clients can use it to apply the change locally instead of fetching the whole list again.
+// For backwards compatibility, each ItemEvent is followed by an ItemsInListChangedEvent, unless the subscriber only
+// wants granular events.
+type ItemEvent struct {
+	Type   EventType           `json:"type"`
+	ListID string              `json:"listID"`
+	Item   db.ShoppingListItem `json:"item"`
+}
+
+func NewItemCreatedEvent(item db.ShoppingListItem) ItemEvent {
+	return ItemEvent{
+		Type:   EventTypeItemCreated,
+		ListID: item.List,
+		Item:   item,
+	}
+}
+
+func NewItemUpdatedEvent(item db.ShoppingListItem) ItemEvent {
+	return ItemEvent{
+		Type:   EventTypeItemUpdated,
+		ListID: item.List,
+		Item:   item,
+	}
+}
+
+func NewItemMovedEvent(item db.ShoppingListItem) ItemEvent {
+	return ItemEvent{
+		Type:   EventTypeItemMoved,
+		ListID: item.List,
+		Item:   item,
+	}
+}
+
+func NewItemDeletedEvent(item db.ShoppingListItem) ItemEvent {
+	return ItemEvent{
+		Type:   EventTypeItemDeleted,
+		ListID: item.List,
+		Item:   item,
+	}
+}
+
+func (ie ItemEvent) GetType() EventType {
+	return ie.Type
+}
+
+func (ie ItemEvent) GetListID() string {
+	return ie.ListID
+}
+
+const EventTypeResyncRequired EventType = "RESYNC_REQUIRED"
+
+// ResyncRequiredMessage is sent to clients that asked for events that are no longer available in the event log.
+// The client needs to refetch its state; all following events have a sequence number greater than Seq.
+type ResyncRequiredMessage struct {
+	Type EventType `json:"type"`
+	Seq  int64     `json:"seq"`
+}
+
+func NewResyncRequiredMessage(seq int64) ResyncRequiredMessage {
+	return ResyncRequiredMessage{
+		Type: EventTypeResyncRequired,
+		Seq:  seq,
+	}
+}
+
+const EventTypeUserJoinedList EventType = "USER_JOINED_LIST"
+const EventTypeUserLeftList EventType = "USER_LEFT_LIST"
+
+// PresenceEvent is sent when a user starts or stops viewing a list. Presence events are not stored in the event log,
+// and therefore have no sequence number.
+type PresenceEvent struct {
+	Type     EventType `json:"type"`
+	ListID   string    `json:"listID"`
+	UserID   int       `json:"userID"`
+	Username string    `json:"username"`
+}
+
+func NewUserJoinedListEvent(listID string, viewer Viewer) PresenceEvent {
+	return PresenceEvent{
+		Type:     EventTypeUserJoinedList,
+		ListID:   listID,
+		UserID:   viewer.UserID,
+		Username: viewer.Username,
+	}
+}
+
+func NewUserLeftListEvent(listID string, viewer Viewer) PresenceEvent {
+	return PresenceEvent{
+		Type:     EventTypeUserLeftList,
+		ListID:   listID,
+		UserID:   viewer.UserID,
+		Username: viewer.Username,
+	}
+}
+
+func (pe PresenceEvent) GetType() EventType {
+	return pe.Type
+}
+
+func (pe PresenceEvent) GetListID() string {
+	return pe.ListID
+}
@@ -0,0 +1,447 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"log/slog"
+
+	"github.com/coder/websocket"
+	"github.com/craftamap/shopping-list/auth"
+	"github.com/craftamap/shopping-list/db"
+)
+
+// events older than eventLogRetention are removed from the event log, and can therefore no longer be replayed.
+const eventLogRetention = 24 * time.Hour
+
+// if more than maxReplayEvents would need to be replayed, the client is asked to resync instead.
+const maxReplayEvents = 500
+
+type message struct {
+	seq       int64
+	eventType EventType
+	listID    string
+	data      []byte
+}
+
+// ErrSlowConsumer is returned by stream if the subscriber could not keep up with the published events, and events had
+// to be dropped. The client is expected to reconnect and replay the missed events.
+var ErrSlowConsumer = errors.New("subscriber too slow to keep up with events")
+
+type subscriber struct {
+	// viewer is the user the subscriber belongs to, used for presence. It is nil if the user is unknown.
+	viewer *Viewer
+
+	msgs chan message
+	// slow is closed as soon as an event could not be sent to the subscriber because its buffer was full
+	slow     chan struct{}
+	slowOnce sync.Once
+
+	listsMu sync.Mutex
+	// lists contains the ids of the lists the subscriber is interested in. If lists is nil, the subscriber receives
+	// events of all lists.
+	lists map[string]bool
+
+	// granularOnly is set for clients that apply the granular item events themselves, and therefore do not need the
+	// ItemsInListChangedEvent sent for older clients after each of them
+	granularOnly bool
+}
+
+func newSubscriber(viewer *Viewer, listIDs []string) *subscriber {
+	sub := &subscriber{
+		viewer: viewer,
+		msgs:   make(chan message, 16),
+		slow:   make(chan struct{}),
+	}
+	if len(listIDs) > 0 {
+		sub.subscribe(listIDs)
+	}
+	return sub
+}
+
+func (s *subscriber) markSlow() {
+	s.slowOnce.Do(func() {
+		close(s.slow)
+	})
+}
+
+func (s *subscriber) subscribe(listIDs []string) {
+	s.listsMu.Lock()
+	defer s.listsMu.Unlock()
+	if s.lists == nil {
+		s.lists = map[string]bool{}
+	}
+	for _, listID := range listIDs {
+		s.lists[listID] = true
+	}
+}
+
+func (s *subscriber) unsubscribe(listIDs []string) {
+	s.listsMu.Lock()
+	defer s.listsMu.Unlock()
+	if s.lists == nil {
+		s.lists = map[string]bool{}
+	}
+	for _, listID := range listIDs {
+		delete(s.lists, listID)
+	}
+}
+
+// subscribedListIDs returns the ids of the lists the subscriber explicitly subscribed to.
+func (s *subscriber) subscribedListIDs() []string {
+	s.listsMu.Lock()
+	defer s.listsMu.Unlock()
+	listIDs := make([]string, 0, len(s.lists))
+	for listID := range s.lists {
+		listIDs = append(listIDs, listID)
+	}
+	return listIDs
+}
+
+// isViewing reports whether the subscriber explicitly subscribed to the list. Subscribers receiving the events of all
+// lists are not considered to be viewing any list.
+func (s *subscriber) isViewing(listID string) bool {
+	s.listsMu.Lock()
+	defer s.listsMu.Unlock()
+	return s.lists != nil && s.lists[listID]
+}
+
+// wants reports whether msg should be sent to the subscriber. Events about lists themselves are sent to every
+// subscriber, as all lists are shown in the overview; events about items only to subscribers of the list.
+// ItemsInListChangedEvents are not sent to subscribers that only want granular events.
+func (s *subscriber) wants(msg message) bool {
+	if s.granularOnly && msg.eventType == EventTypeItemsInListChanged {
+		return false
+	}
+	if msg.eventType == EventTypeListCreated || msg.eventType == EventTypeListUpdated || msg.eventType == EventTypeListDeleted || msg.listID == "" {
+		return true
+	}
+	s.listsMu.Lock()
+	defer s.listsMu.Unlock()
+	return s.lists == nil || s.lists[msg.listID]
+}
+
+type EventHub struct {
+	subscribersMu sync.Mutex
+	subscribers   map[*subscriber]bool
+
+	// publishMu ensures that events are fanned out in the order of their sequence numbers
+	publishMu sync.Mutex
+	eventRepo *db.EventRepository
+	userRepo  *db.UserRepository
+
+	// presenceMu guards viewers, which contains the last known viewers per list id
+	presenceMu sync.Mutex
+	viewers    map[string]map[int]Viewer
+
+	metrics metrics
+}
+
+func New(eventRepo *db.EventRepository, userRepo *db.UserRepository) *EventHub {
+	return &EventHub{
+		subscribers:   map[*subscriber]bool{},
+		subscribersMu: sync.Mutex{},
+		eventRepo:     eventRepo,
+		userRepo:      userRepo,
+		viewers:       map[string]map[int]Viewer{},
+	}
+}
+
+func (eh *EventHub) addSubscriber(sub *subscriber) {
+	eh.subscribersMu.Lock()
+	eh.subscribers[sub] = true
+	eh.subscribersMu.Unlock()
+	eh.updatePresence(sub.subscribedListIDs())
+}
+
+func (eh *EventHub) removeSubscriber(sub *subscriber) {
+	eh.subscribersMu.Lock()
+	delete(eh.subscribers, sub)
+	eh.subscribersMu.Unlock()
+	eh.updatePresence(sub.subscribedListIDs())
+}
+
+// findViewer determines the user of the request, so that it can be shown to other users viewing the same lists.
+func (eh *EventHub) findViewer(r *http.Request) *Viewer {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		return nil
+	}
+	user, err := eh.userRepo.FindById(r.Context(), userID)
+	if err != nil {
+		slog.Warn("failed to find user of subscriber", "userID", userID, "err", err)
+		return nil
+	}
+	return &Viewer{UserID: user.ID, Username: user.Username}
+}
+
+func (eh *EventHub) countSubscribers() int {
+	eh.subscribersMu.Lock()
+	defer eh.subscribersMu.Unlock()
+	return len(eh.subscribers)
+}
+
+// parseSince reads the sequence number of the last event the client has seen from the since query parameter, or, if
+// it is not set, from the Last-Event-ID header used by EventSource.
+func parseSince(r *http.Request) (*int64, error) {
+	rawSince := r.URL.Query().Get("since")
+	if rawSince == "" {
+		rawSince = r.Header.Get("Last-Event-ID")
+	}
+	if rawSince == "" {
+		return nil, nil
+	}
+	since, err := strconv.ParseInt(rawSince, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid since %s: %w", rawSince, err)
+	}
+	return &since, nil
+}
+
+// wantsGranularOnly reports whether the client opted out of ItemsInListChangedEvents using the granularOnly query
+// parameter, as it applies the granular item events itself.
+func wantsGranularOnly(r *http.Request) bool {
+	return r.URL.Query().Get("granularOnly") == "true"
+}
+
+// withSeq adds the sequence number to a marshalled event.
+func withSeq(payload json.RawMessage, seq int64) ([]byte, error) {
+	fields := map[string]json.RawMessage{}
+	err := json.Unmarshal(payload, &fields)
+	if err != nil {
+		return nil, err
+	}
+	fields["seq"] = json.RawMessage(strconv.FormatInt(seq, 10))
+	return json.Marshal(fields)
+}
+
+// replay determines the messages a client that has seen all events up to since has missed.
+// If the missed events are no longer in the event log, a ResyncRequiredMessage is returned instead.
+func (eh *EventHub) replay(ctx context.Context, since int64) ([]message, error) {
+	oldest, latest, err := eh.eventRepo.FindSeqBounds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if since == latest {
+		return nil, nil
+	}
+
+	storedEvents := []db.StoredEvent{}
+	// since > latest means that the client knows events we don't, e.g. because the database was reset
+	if since < latest && since >= oldest-1 {
+		storedEvents, err = eh.eventRepo.FindAllSince(ctx, since, maxReplayEvents+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(storedEvents) == 0 || len(storedEvents) > maxReplayEvents {
+		msg, err := json.Marshal(NewResyncRequiredMessage(latest))
+		if err != nil {
+			return nil, err
+		}
+		return []message{{seq: latest, eventType: EventTypeResyncRequired, data: msg}}, nil
+	}
+
+	msgs := make([]message, 0, len(storedEvents))
+	for _, storedEvent := range storedEvents {
+		msg, err := messageFromStoredEvent(storedEvent)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// EventsSince returns the events published after since, as they are sent to clients, and the sequence number of the
+// latest event. If the events are no longer available in the event log, it returns false, and the client needs to
+// resync.
+func (eh *EventHub) EventsSince(ctx context.Context, since int64) ([]json.RawMessage, int64, bool, error) {
+	msgs, err := eh.replay(ctx, since)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	latest := since
+	data := []json.RawMessage{}
+	for _, msg := range msgs {
+		if msg.eventType == EventTypeResyncRequired {
+			return nil, msg.seq, false, nil
+		}
+		data = append(data, msg.data)
+		latest = msg.seq
+	}
+	return data, latest, true, nil
+}
+
+func messageFromStoredEvent(storedEvent db.StoredEvent) (message, error) {
+	data, err := withSeq(storedEvent.Payload, storedEvent.Seq)
+	if err != nil {
+		return message{}, err
+	}
+	var listID string
+	if storedEvent.ListID != nil {
+		listID = *storedEvent.ListID
+	}
+	return message{seq: storedEvent.Seq, eventType: EventType(storedEvent.Type), listID: listID, data: data}, nil
+}
+
+// stream sends the events the subscriber is interested in using send. If since is set, the events the client missed
+// are replayed first. stream returns when ctx is done or sending fails.
+func (eh *EventHub) stream(ctx context.Context, sub *subscriber, since *int64, send func(ctx context.Context, msg message) error) error {
+	// the subscriber is registered before replaying, so that no event gets lost in between. Events that are both
+	// replayed and received afterwards are skipped by their sequence number.
+	var lastSeq int64
+	if since != nil {
+		lastSeq = *since
+		replayMsgs, err := eh.replay(ctx, *since)
+		if err != nil {
+			return fmt.Errorf("failed to replay events: %w", err)
+		}
+		for _, msg := range replayMsgs {
+			if !sub.wants(msg) {
+				lastSeq = msg.seq
+				continue
+			}
+			err := send(ctx, msg)
+			if err != nil {
+				return err
+			}
+			lastSeq = msg.seq
+		}
+	}
+
+	for {
+		select {
+		case <-sub.slow:
+			return ErrSlowConsumer
+		case msg := <-sub.msgs:
+			// messages without sequence number are ephemeral, and can not be replayed
+			if msg.seq == 0 {
+				err := send(ctx, msg)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			if msg.seq <= lastSeq {
+				continue
+			}
+			err := send(ctx, msg)
+			if err != nil {
+				return err
+			}
+			lastSeq = msg.seq
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Publish stores the event in the event log, assigning it a sequence number, and sends it to all subscribers.
+// This makes EventHub an EventBus that only delivers events to subscribers of the same process.
+func (eh *EventHub) Publish(event Event) error {
+	start := time.Now()
+
+	eh.publishMu.Lock()
+	defer eh.publishMu.Unlock()
+
+	msg, err := eh.store(event)
+	if err != nil {
+		return err
+	}
+	eh.deliver(msg)
+
+	eh.metrics.observePublish(time.Since(start))
+	return nil
+}
+
+// store appends the event to the event log, and returns the message that should be sent to subscribers.
+func (eh *EventHub) store(event Event) (message, error) {
+	slog.Info("Publishing event", "event", event)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return message{}, err
+	}
+
+	ctx := context.Background()
+	var listID *string
+	if event.GetListID() != "" {
+		id := event.GetListID()
+		listID = &id
+	}
+	seq, err := eh.eventRepo.Create(ctx, string(event.GetType()), listID, payload)
+	if err != nil {
+		return message{}, err
+	}
+	msg, err := withSeq(payload, seq)
+	if err != nil {
+		return message{}, err
+	}
+
+	if seq%100 == 0 {
+		err := eh.eventRepo.DeleteOlderThan(ctx, time.Now().Add(-eventLogRetention))
+		if err != nil {
+			slog.Warn("failed to remove old events from event log", "err", err)
+		}
+	}
+
+	return message{seq: seq, eventType: event.GetType(), listID: event.GetListID(), data: msg}, nil
+}
+
+// deliver sends the message to all subscribers interested in it. Messages must be delivered in the order of their
+// sequence numbers.
+func (eh *EventHub) deliver(msg message) {
+	eh.subscribersMu.Lock()
+	defer eh.subscribersMu.Unlock()
+
+	for sub := range eh.subscribers {
+		if !sub.wants(msg) {
+			continue
+		}
+		eh.send(sub, msg)
+	}
+}
+
+// send sends the message to the subscriber, or marks the subscriber as slow if its buffer is full. subscribersMu must
+// be held.
+func (eh *EventHub) send(sub *subscriber, msg message) {
+	select {
+	case sub.msgs <- msg:
+	default:
+		// the subscriber missed an event, so it has to be disconnected, allowing the client to replay the events
+		slog.Warn("failed to publish message to subscriber, no space left in buffer; disconnecting subscriber")
+		eh.metrics.droppedEvents.Add(1)
+		sub.markSlow()
+	}
+}
+
+// EstablishConnection streams events to the client, using Server-Sent Events if the client accepts text/event-stream,
+// and websockets otherwise. Commands sent over websockets are executed by commandHandler.
+func EstablishConnection(hub *EventHub, commandHandler CommandHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			err = hub.subscribeSSE(r.Context(), w, r)
+		} else {
+			err = hub.subscribeWebsocket(r.Context(), w, r, commandHandler)
+		}
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if websocket.CloseStatus(err) == websocket.StatusNormalClosure ||
+			websocket.CloseStatus(err) == websocket.StatusGoingAway {
+			return
+		}
+		if err != nil {
+			slog.Error("error during event stream connection", "err", err)
+			return
+		}
+	}
+}
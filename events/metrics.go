@@ -0,0 +1,72 @@
+package events
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// publishLatencyBuckets are the upper bounds of the publish latency histogram buckets, in seconds.
+var publishLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+type metrics struct {
+	droppedEvents         atomic.Int64
+	slowSubscribersClosed atomic.Int64
+
+	publishLatencyMu     sync.Mutex
+	publishLatencyCounts []int64 // cumulative count per bucket in publishLatencyBuckets
+	publishLatencySum    float64
+	publishLatencyCount  int64
+}
+
+func (m *metrics) observePublish(d time.Duration) {
+	m.publishLatencyMu.Lock()
+	defer m.publishLatencyMu.Unlock()
+	if m.publishLatencyCounts == nil {
+		m.publishLatencyCounts = make([]int64, len(publishLatencyBuckets))
+	}
+	seconds := d.Seconds()
+	for i, bound := range publishLatencyBuckets {
+		if seconds <= bound {
+			m.publishLatencyCounts[i]++
+		}
+	}
+	m.publishLatencySum += seconds
+	m.publishLatencyCount++
+}
+
+// MetricsHandler exposes the metrics of the hub in the Prometheus text format.
+func MetricsHandler(hub *EventHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		fmt.Fprintln(w, "# HELP shoppinglist_events_active_subscribers Number of currently connected event subscribers.")
+		fmt.Fprintln(w, "# TYPE shoppinglist_events_active_subscribers gauge")
+		fmt.Fprintf(w, "shoppinglist_events_active_subscribers %d\n", hub.countSubscribers())
+
+		fmt.Fprintln(w, "# HELP shoppinglist_events_dropped_total Number of events that could not be sent to a subscriber because its buffer was full.")
+		fmt.Fprintln(w, "# TYPE shoppinglist_events_dropped_total counter")
+		fmt.Fprintf(w, "shoppinglist_events_dropped_total %d\n", hub.metrics.droppedEvents.Load())
+
+		fmt.Fprintln(w, "# HELP shoppinglist_events_slow_subscribers_closed_total Number of subscribers disconnected for being too slow.")
+		fmt.Fprintln(w, "# TYPE shoppinglist_events_slow_subscribers_closed_total counter")
+		fmt.Fprintf(w, "shoppinglist_events_slow_subscribers_closed_total %d\n", hub.metrics.slowSubscribersClosed.Load())
+
+		hub.metrics.publishLatencyMu.Lock()
+		defer hub.metrics.publishLatencyMu.Unlock()
+		fmt.Fprintln(w, "# HELP shoppinglist_events_publish_duration_seconds Time it takes to store and fan out an event.")
+		fmt.Fprintln(w, "# TYPE shoppinglist_events_publish_duration_seconds histogram")
+		for i, bound := range publishLatencyBuckets {
+			var count int64
+			if hub.metrics.publishLatencyCounts != nil {
+				count = hub.metrics.publishLatencyCounts[i]
+			}
+			fmt.Fprintf(w, "shoppinglist_events_publish_duration_seconds_bucket{le=\"%g\"} %d\n", bound, count)
+		}
+		fmt.Fprintf(w, "shoppinglist_events_publish_duration_seconds_bucket{le=\"+Inf\"} %d\n", hub.metrics.publishLatencyCount)
+		fmt.Fprintf(w, "shoppinglist_events_publish_duration_seconds_sum %g\n", hub.metrics.publishLatencySum)
+		fmt.Fprintf(w, "shoppinglist_events_publish_duration_seconds_count %d\n", hub.metrics.publishLatencyCount)
+	}
+}
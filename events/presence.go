@@ -0,0 +1,99 @@
+package events
+
+import (
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// Viewer is a user that currently has a list open, i.e. is connected and subscribed to the list.
+type Viewer struct {
+	UserID   int    `json:"userID"`
+	Username string `json:"username"`
+}
+
+// Viewers returns the users currently viewing the list, sorted by username. Only subscribers connected to this
+// server instance are taken into account.
+func (eh *EventHub) Viewers(listID string) []Viewer {
+	eh.presenceMu.Lock()
+	defer eh.presenceMu.Unlock()
+
+	viewers := []Viewer{}
+	for _, viewer := range eh.viewers[listID] {
+		viewers = append(viewers, viewer)
+	}
+	slices.SortFunc(viewers, func(a, b Viewer) int {
+		return strings.Compare(a.Username, b.Username)
+	})
+	return viewers
+}
+
+// findViewers determines which users are currently viewing the given list, based on the connected subscribers.
+func (eh *EventHub) findViewers(listID string) map[int]Viewer {
+	eh.subscribersMu.Lock()
+	defer eh.subscribersMu.Unlock()
+
+	viewers := map[int]Viewer{}
+	for sub := range eh.subscribers {
+		if sub.viewer != nil && sub.isViewing(listID) {
+			viewers[sub.viewer.UserID] = *sub.viewer
+		}
+	}
+	return viewers
+}
+
+// updatePresence recalculates the viewers of the given lists, and sends presence events for every user that started
+// or stopped viewing one of them.
+func (eh *EventHub) updatePresence(listIDs []string) {
+	eh.presenceMu.Lock()
+	defer eh.presenceMu.Unlock()
+
+	for _, listID := range listIDs {
+		previousViewers := eh.viewers[listID]
+		currentViewers := eh.findViewers(listID)
+
+		presenceEvents := []PresenceEvent{}
+		for userID, viewer := range currentViewers {
+			if _, ok := previousViewers[userID]; !ok {
+				presenceEvents = append(presenceEvents, NewUserJoinedListEvent(listID, viewer))
+			}
+		}
+		for userID, viewer := range previousViewers {
+			if _, ok := currentViewers[userID]; !ok {
+				presenceEvents = append(presenceEvents, NewUserLeftListEvent(listID, viewer))
+			}
+		}
+
+		if len(currentViewers) == 0 {
+			delete(eh.viewers, listID)
+		} else {
+			eh.viewers[listID] = currentViewers
+		}
+
+		for _, presenceEvent := range presenceEvents {
+			data, err := json.Marshal(presenceEvent)
+			if err != nil {
+				slog.Error("failed to marshal presence event", "err", err)
+				continue
+			}
+			// presence is ephemeral, so it is delivered without being stored in the event log
+			eh.deliverPresence(message{eventType: presenceEvent.Type, listID: listID, data: data}, presenceEvent.UserID)
+		}
+	}
+}
+
+// deliverPresence sends a presence message about the given user to the subscribers that explicitly subscribed to the
+// list, except for the subscribers of the user itself. Subscribers receiving the events of all lists do not show who
+// is viewing a list, and users do not need to be told that they joined.
+func (eh *EventHub) deliverPresence(msg message, userID int) {
+	eh.subscribersMu.Lock()
+	defer eh.subscribersMu.Unlock()
+
+	for sub := range eh.subscribers {
+		if !sub.isViewing(msg.listID) || (sub.viewer != nil && sub.viewer.UserID == userID) {
+			continue
+		}
+		eh.send(sub, msg)
+	}
+}
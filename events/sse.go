@@ -0,0 +1,89 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const sseHeartbeatInterval = 15 * time.Second
+
+// subscribeSSE streams events as Server-Sent Events, for clients that can not establish a websocket connection.
+// The payloads are the same as for websockets; the sequence number is additionally sent as the event id, so that
+// EventSource sends it as Last-Event-ID when reconnecting.
+func (eh *EventHub) subscribeSSE(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	since, err := parseSince(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	rc := http.NewResponseController(w)
+
+	sub := newSubscriber(eh.findViewer(r), r.URL.Query()["list"])
+	sub.granularOnly = wantsGranularOnly(r)
+	eh.addSubscriber(sub)
+	defer eh.removeSubscriber(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// writes happen both from the event stream and the heartbeat, so they need to be serialized
+	var writeMu sync.Mutex
+	write := func(s string) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		err := rc.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprint(w, s)
+		if err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	err = write("retry: 1000\n\n")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		defer cancel()
+		ticker := time.NewTicker(sseHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				err := write(": heartbeat\n\n")
+				if err != nil {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	err = eh.stream(ctx, sub, since, func(ctx context.Context, msg message) error {
+		// messages without sequence number, like presence, must not reset the id EventSource sends when reconnecting
+		if msg.seq == 0 {
+			return write(fmt.Sprintf("data: %s\n\n", msg.data))
+		}
+		return write(fmt.Sprintf("id: %d\ndata: %s\n\n", msg.seq, msg.data))
+	})
+	if errors.Is(err, ErrSlowConsumer) {
+		// ending the response makes EventSource reconnect, sending the id of the last event it received
+		eh.metrics.slowSubscribersClosed.Add(1)
+		return nil
+	}
+	return err
+}
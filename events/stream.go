@@ -0,0 +1,107 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// StreamedEvent is an event passed to in-process consumers by Stream.
+type StreamedEvent struct {
+	Seq    int64
+	Type   EventType
+	ListID string
+	// Data is the event marshalled to JSON, as sent to clients
+	Data []byte
+}
+
+// FindEvent returns the event with the given sequence number from the event log, or false if it is no longer stored.
+func (eh *EventHub) FindEvent(ctx context.Context, seq int64) (StreamedEvent, bool, error) {
+	storedEvents, err := eh.eventRepo.FindAllSince(ctx, seq-1, 1)
+	if err != nil {
+		return StreamedEvent{}, false, err
+	}
+	if len(storedEvents) == 0 || storedEvents[0].Seq != seq {
+		return StreamedEvent{}, false, nil
+	}
+	msg, err := messageFromStoredEvent(storedEvents[0])
+	if err != nil {
+		return StreamedEvent{}, false, err
+	}
+	return StreamedEvent{Seq: msg.seq, Type: msg.eventType, ListID: msg.listID, Data: msg.data}, true, nil
+}
+
+// Stream calls handle for every event published after since, or after Stream was called if since is nil, until ctx is
+// done or handle fails. Unlike clients, Stream is not disconnected if handle is too slow to keep up; instead, missed
+// events are replayed from the event log. Ephemeral events, like presence events, are not passed to handle.
+func (eh *EventHub) Stream(ctx context.Context, since *int64, handle func(event StreamedEvent) error) error {
+	var lastSeq int64
+	if since != nil {
+		lastSeq = *since
+	} else {
+		_, latest, err := eh.eventRepo.FindSeqBounds(ctx)
+		if err != nil {
+			return err
+		}
+		lastSeq = latest
+	}
+
+	// stream asks clients to resync instead of replaying many events, so a consumer that is far behind catches up in
+	// pages first
+	if since != nil {
+		oldest, _, err := eh.eventRepo.FindSeqBounds(ctx)
+		if err != nil {
+			return err
+		}
+		if lastSeq < oldest-1 {
+			slog.Warn("events are no longer in event log, skipping them", "from", lastSeq, "to", oldest-1)
+		}
+		for {
+			storedEvents, err := eh.eventRepo.FindAllSince(ctx, lastSeq, maxReplayEvents)
+			if err != nil {
+				return err
+			}
+			for _, storedEvent := range storedEvents {
+				msg, err := messageFromStoredEvent(storedEvent)
+				if err != nil {
+					return err
+				}
+				err = handle(StreamedEvent{Seq: msg.seq, Type: msg.eventType, ListID: msg.listID, Data: msg.data})
+				if err != nil {
+					return err
+				}
+				lastSeq = msg.seq
+			}
+			if len(storedEvents) < maxReplayEvents {
+				break
+			}
+		}
+	}
+
+	for {
+		sub := newSubscriber(nil, nil)
+		eh.addSubscriber(sub)
+		err := eh.stream(ctx, sub, &lastSeq, func(ctx context.Context, msg message) error {
+			if msg.seq == 0 {
+				return nil
+			}
+			if msg.eventType == EventTypeResyncRequired {
+				slog.Warn("events are no longer in event log, skipping them", "from", lastSeq, "to", msg.seq)
+				lastSeq = msg.seq
+				return nil
+			}
+			err := handle(StreamedEvent{Seq: msg.seq, Type: msg.eventType, ListID: msg.listID, Data: msg.data})
+			if err != nil {
+				return err
+			}
+			lastSeq = msg.seq
+			return nil
+		})
+		eh.removeSubscriber(sub)
+		if errors.Is(err, ErrSlowConsumer) {
+			slog.Warn("in-process consumer too slow to keep up with events, replaying missed events")
+			continue
+		}
+		return err
+	}
+}
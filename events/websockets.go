@@ -4,103 +4,111 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
-	"sync"
 	"time"
 
-	"log/slog"
-
 	"github.com/coder/websocket"
 )
 
-type subscriber struct {
-	msgs chan []byte
-	//closeSlow?
+// StatusSlowConsumer is the close code used for clients that could not keep up with the published events. Clients
+// should reconnect, passing the sequence number of the last event they received as since.
+const StatusSlowConsumer websocket.StatusCode = 4000
+
+// clientMessage is a message sent by the client over the websocket connection. Besides SUBSCRIBE and UNSUBSCRIBE,
+// every type is treated as a command, which is acknowledged using the RequestID.
+type clientMessage struct {
+	Type      string          `json:"type"`
+	ListIDs   []string        `json:"listIDs"`
+	RequestID string          `json:"requestID"`
+	Payload   json.RawMessage `json:"payload"`
 }
 
-type EventHub struct {
-	subscribersMu sync.Mutex
-	subscribers   map[*subscriber]bool
-}
+// handleClientMessage handles a message sent by the client, and returns the reply that should be sent back, if any.
+func (eh *EventHub) handleClientMessage(ctx context.Context, sub *subscriber, commandHandler CommandHandler, data []byte) ([]byte, error) {
+	var msg clientMessage
+	err := json.Unmarshal(data, &msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse client message: %w", err)
+	}
+	switch msg.Type {
+	case "SUBSCRIBE":
+		sub.subscribe(msg.ListIDs)
+		eh.updatePresence(msg.ListIDs)
+		return nil, nil
+	case "UNSUBSCRIBE":
+		sub.unsubscribe(msg.ListIDs)
+		eh.updatePresence(msg.ListIDs)
+		return nil, nil
+	}
 
-func New() *EventHub {
-	return &EventHub{
-		subscribers:   map[*subscriber]bool{},
-		subscribersMu: sync.Mutex{},
+	if msg.RequestID == "" {
+		return nil, fmt.Errorf("command %s without requestID", msg.Type)
+	}
+	var result any
+	if commandHandler == nil {
+		err = fmt.Errorf("commands are not supported")
+	} else {
+		result, err = commandHandler.HandleCommand(ctx, Command{Type: msg.Type, Payload: msg.Payload})
 	}
+	if err != nil {
+		slog.Info("command failed", "type", msg.Type, "requestID", msg.RequestID, "err", err)
+	}
+	return json.Marshal(NewAck(msg.RequestID, result, err))
 }
 
-func (eh *EventHub) subscribeWebsocket(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	sub := &subscriber{
-		msgs: make(chan []byte, 16),
+func (eh *EventHub) subscribeWebsocket(ctx context.Context, w http.ResponseWriter, r *http.Request, commandHandler CommandHandler) error {
+	since, err := parseSince(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
 	}
 
-	eh.subscribersMu.Lock()
-	eh.subscribers[sub] = true
-	eh.subscribersMu.Unlock()
-	defer func() {
-		eh.subscribersMu.Lock()
-		delete(eh.subscribers, sub)
-		eh.subscribersMu.Unlock()
-	}()
+	sub := newSubscriber(eh.findViewer(r), r.URL.Query()["list"])
+	sub.granularOnly = wantsGranularOnly(r)
+	eh.addSubscriber(sub)
+	defer eh.removeSubscriber(sub)
 
 	c, err := websocket.Accept(w, r, nil)
 	if err != nil {
 		return err
 	}
 	defer c.CloseNow()
-	// we dont expect any data from the websocket, as we do unidirectional communication
-	ctx = c.CloseRead(ctx)
 
-	for {
-		select {
-		case msg := <-sub.msgs:
-			err := writeTimeout(ctx, time.Second*5, c, msg)
+	// the client can change its list subscriptions and send commands over the connection. Commands are executed one
+	// after another, in the order they were received.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			_, data, err := c.Read(ctx)
 			if err != nil {
-				return err
+				return
+			}
+			reply, err := eh.handleClientMessage(ctx, sub, commandHandler, data)
+			if err != nil {
+				slog.Warn("failed to handle client message", "err", err)
+				continue
+			}
+			if reply != nil {
+				err := writeTimeout(ctx, time.Second*5, c, reply)
+				if err != nil {
+					return
+				}
 			}
-		case <-ctx.Done():
-			return ctx.Err()
-		}
-	}
-
-}
-
-func (eh *EventHub) Publish(event Event) error {
-	slog.Info("Publishing event", "event", event)
-	msg, err := json.Marshal(event)
-	if err != nil {
-		return err
-	}
-
-	eh.subscribersMu.Lock()
-	defer eh.subscribersMu.Unlock()
-
-	for sub := range eh.subscribers {
-		select {
-		case sub.msgs <- msg:
-		default:
-			slog.Warn("failed to publish message to subscriber, no space left in buffer")
 		}
-	}
-	return nil
-}
+	}()
 
-func EstablishConnection(hub *EventHub) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		err := hub.subscribeWebsocket(r.Context(), w, r)
-		if errors.Is(err, context.Canceled) {
-			return
-		}
-		if websocket.CloseStatus(err) == websocket.StatusNormalClosure ||
-			websocket.CloseStatus(err) == websocket.StatusGoingAway {
-			return
-		}
-		if err != nil {
-			slog.Error("error during websocket connection", "err", err)
-			return
-		}
+	err = eh.stream(ctx, sub, since, func(ctx context.Context, msg message) error {
+		return writeTimeout(ctx, time.Second*5, c, msg.data)
+	})
+	if errors.Is(err, ErrSlowConsumer) {
+		eh.metrics.slowSubscribersClosed.Add(1)
+		return c.Close(StatusSlowConsumer, "too slow to keep up with events, reconnect to replay missed events")
 	}
+	return err
 }
 
 func writeTimeout(ctx context.Context, timeout time.Duration, c *websocket.Conn, msg []byte) error {
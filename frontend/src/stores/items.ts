@@ -4,14 +4,30 @@ export interface ShoppingListItem {
     id: string,
     text: string,
     checked: boolean,
+    quantity: number | null,
+    unit: string | null,
+    note: string | null,
+    category: string | null,
     parent: string | null,
     list: string,
     sort: number,
+    version: number,
+}
+
+// itemText returns the text of the item, prefixed with its quantity and unit, e.g. "500 g butter"
+export function itemText(item: ShoppingListItem): string {
+    if (item.quantity === null) {
+        return item.text
+    }
+    const quantity = item.unit ? `${item.quantity} ${item.unit}` : `${item.quantity}x`
+    return item.text ? `${quantity} ${item.text}` : quantity
 }
 
 export const useItemsStore = defineStore('items', {
     state: () => ({
         itemsByList: {} as Record<string, ShoppingListItem[]>,
+        // openListIds contains the lists currently shown; we only subscribe to their events
+        openListIds: [] as string[],
         fetchState: {} as Record<string, {
             promise: Promise<void>,
             pending: boolean,
@@ -54,6 +70,34 @@ export const useItemsStore = defineStore('items', {
                 obj.settled = true;
             })
         },
+        open(listId: string) {
+            if (!this.openListIds.includes(listId)) {
+                this.openListIds = [...this.openListIds, listId]
+            }
+        },
+        close(listId: string) {
+            this.openListIds = this.openListIds.filter((id) => id !== listId)
+        },
+        // applyEvent applies an item event received over the websocket to the items of the list, if they were fetched
+        applyEvent(type: string, item: ShoppingListItem) {
+            const items = this.itemsByList[item.list]
+            if (!items) {
+                return
+            }
+            const existing = items.find((i) => i.id === item.id)
+            // events can arrive after the list was fetched again, in which case they are outdated
+            if (existing && existing.version > item.version) {
+                return
+            }
+
+            const others = items.filter((i) => i.id !== item.id)
+            this.itemsByList = {
+                ...this.itemsByList,
+                [item.list]: type === 'ITEM_DELETED'
+                    ? others
+                    : [...others, item].sort((a, b) => a.sort - b.sort),
+            }
+        },
         async update(listId: string, itemId: string, { checked, text }: { checked?: boolean, text?: string }) {
             await fetch(`/api/list/${listId}/item/${itemId}`, {
                 method: 'PATCH',
@@ -76,6 +76,10 @@ export const useListsStore = defineStore('lists', {
                 return this.fetch(id)
             }
         },
+        remove(id: string) {
+            const { [id]: _, ...lists } = this.lists
+            this.lists = lists
+        },
         async setItemToMove(listId: string, itemId: string | undefined) {
             this.itemToMove[listId] = itemId
         }
@@ -1,4 +1,4 @@
-import { onMounted, ref } from "vue";
+import { onMounted, ref, watch } from "vue";
 import { useListsStore } from "./stores/lists";
 import { useItemsStore } from "./stores/items";
 
@@ -6,29 +6,81 @@ export function useWebSocket() {
     const ws = ref<WebSocket | null>(null);
     const listsStore = useListsStore()
     const itemsStore = useItemsStore()
+    // sequence number of the last event we received; used to replay missed events after reconnecting
+    let lastSeq: number | null = null
+
+    // we only receive item events for lists we subscribed to - we subscribe to the lists that are open, and
+    // unsubscribe once they are closed, so that other users no longer see us viewing them
+    const send = (type: "SUBSCRIBE" | "UNSUBSCRIBE", listIDs: string[]) => {
+        if (ws.value?.readyState !== WebSocket.OPEN) {
+            return
+        }
+        ws.value.send(JSON.stringify({
+            type,
+            listIDs,
+        }))
+    }
+
+    watch(() => itemsStore.openListIds, (listIDs, previousListIDs) => {
+        const opened = listIDs.filter((id) => !previousListIDs.includes(id))
+        const closed = previousListIDs.filter((id) => !listIDs.includes(id))
+        if (opened.length > 0) {
+            send("SUBSCRIBE", opened)
+        }
+        if (closed.length > 0) {
+            send("UNSUBSCRIBE", closed)
+        }
+    })
 
     const connect = () => {
         // location.host contains the port for some reason
-        ws.value = new WebSocket(`${location.protocol === 'http:' ? 'ws' : 'wss'}://${location.host}/api/events/`)
+        // we apply the granular item events ourselves, so we don't need ITEMS_IN_LIST_CHANGED
+        const since = lastSeq !== null ? `&since=${lastSeq}` : ''
+        ws.value = new WebSocket(`${location.protocol === 'http:' ? 'ws' : 'wss'}://${location.host}/api/events/?granularOnly=true${since}`)
         ws.value.addEventListener('open', () => {
             console.log("WebSocket connected.");
+            send("SUBSCRIBE", itemsStore.openListIds)
         })
 
         ws.value.addEventListener('error', (err) => {
             console.log("WebSocket error", err);
         })
 
+        ws.value.addEventListener('close', () => {
+            console.log("WebSocket closed, reconnecting.");
+            setTimeout(connect, 1000)
+        })
+
         ws.value.addEventListener('message', (event) => {
             console.log(event)
             const data = JSON.parse(event.data)
+            if (typeof data.seq === 'number') {
+                lastSeq = data.seq
+            }
 
             switch (data.type) {
                 case "LIST_CREATED":
                 case "LIST_UPDATED":
-                    listsStore.fetch(data.listID)
+                    if (data.list?.archivedAt) {
+                        listsStore.remove(data.listID)
+                    } else {
+                        listsStore.fetch(data.listID)
+                    }
+                    break
+                case "LIST_DELETED":
+                    listsStore.remove(data.listID)
+                    break
+                case "ITEM_CREATED":
+                case "ITEM_UPDATED":
+                case "ITEM_MOVED":
+                case "ITEM_DELETED":
+                    itemsStore.applyEvent(data.type, data.item)
                     break
-                case "ITEMS_IN_LIST_CHANGED":
-                    itemsStore.fetch(data.listID)
+                case "RESYNC_REQUIRED":
+                    listsStore.fetchAll()
+                    for (const listId of Object.keys(itemsStore.itemsByList)) {
+                        itemsStore.fetch(listId)
+                    }
             }
         })
     }
@@ -5,13 +5,18 @@ import (
 	"database/sql"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"net/http"
 	"os"
 	"os/signal"
 	"slices"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"log/slog"
 
@@ -20,6 +25,7 @@ import (
 	"github.com/craftamap/shopping-list/events"
 	"github.com/craftamap/shopping-list/services"
 	"github.com/craftamap/shopping-list/session"
+	"github.com/craftamap/shopping-list/webhooks"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/urfave/cli/v3"
 )
@@ -30,14 +36,707 @@ var embedFrontendFS embed.FS
 //go:embed schema
 var embedSchemaFS embed.FS
 
+// getAllLists returns all lists that are not archived. The query parameter archived can be set to "true" to only get
+// archived lists, or to "all" to get all lists.
 func getAllLists(listService *services.ListService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		lists, err := listService.GetAll(r.Context())
+		archived := new(bool)
+		switch r.URL.Query().Get("archived") {
+		case "", "false":
+		case "true":
+			*archived = true
+		case "all":
+			archived = nil
+		default:
+			http.Error(w, "invalid value for archived", 400)
+			return
+		}
+
+		lists, err := listService.GetAll(r.Context(), archived)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(lists)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+// listDetails contains the fields of a list that can be sent by clients when creating or updating a list.
+type listDetails struct {
+	Status      *string `json:"status"`
+	Title       *string `json:"title"`
+	Notes       *string `json:"notes"`
+	PlannedDate *string `json:"plannedDate"`
+	Store       *string `json:"store"`
+	Archived    *bool   `json:"archived"`
+}
+
+func (ld listDetails) toService(ifVersion *int) services.ListDetails {
+	return services.ListDetails{
+		Status:      ld.Status,
+		Title:       ld.Title,
+		Notes:       ld.Notes,
+		PlannedDate: ld.PlannedDate,
+		Store:       ld.Store,
+		Archived:    ld.Archived,
+		IfVersion:   ifVersion,
+	}
+}
+
+func createList(listService *services.ListService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			listDetails
+			// Templates contains the ids of the templates whose items are added to the list
+			Templates []string `json:"templates"`
+		}
+		// the body is optional, as lists can be created without any details
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		list, err := listService.CreateFromTemplates(r.Context(), body.toService(nil), body.Templates)
+		if errors.Is(err, services.ErrInvalidList) {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(list)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+// parseIfMatch returns the version sent by the client in the If-Match header, or nil if the header is not set.
+func parseIfMatch(r *http.Request) (*int, error) {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || ifMatch == "*" {
+		return nil, nil
+	}
+	version, err := strconv.Atoi(strings.Trim(ifMatch, `"`))
+	if err != nil {
+		return nil, fmt.Errorf("invalid If-Match header: %w", err)
+	}
+	return &version, nil
+}
+
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, version))
+}
+
+// writeConflict responds with 409 Conflict, containing the current state of the item or list.
+func writeConflict(w http.ResponseWriter, current any, version int) {
+	setETag(w, version)
+	w.WriteHeader(http.StatusConflict)
+	err := json.NewEncoder(w).Encode(current)
+	if err != nil {
+		slog.Error("failed to encode current state", "err", err)
+	}
+}
+
+func writeItemConflict(w http.ResponseWriter, r *http.Request, itemService *services.ItemService, itemId string) {
+	item, err := itemService.FindById(r.Context(), itemId)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	writeConflict(w, item, item.Version)
+}
+
+func getList(listService *services.ListService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("listId")
+		list, err := listService.FindById(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		setETag(w, list.Version)
+		err = json.NewEncoder(w).Encode(list)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+func updateList(listService *services.ListService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listId := r.PathValue("listId")
+		var details listDetails
+		err := json.NewDecoder(r.Body).Decode(&details)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		ifVersion, err := parseIfMatch(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		list, err := listService.Update(r.Context(), listId, details.toService(ifVersion))
+		if errors.Is(err, services.ErrVersionConflict) {
+			current, err := listService.FindById(r.Context(), listId)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			writeConflict(w, current, current.Version)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidList) {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		setETag(w, list.Version)
+		err = json.NewEncoder(w).Encode(list)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+func deleteList(listService *services.ListService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listId := r.PathValue("listId")
+		ifVersion, err := parseIfMatch(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		err = listService.Delete(r.Context(), listId, ifVersion)
+		if errors.Is(err, services.ErrVersionConflict) {
+			current, err := listService.FindById(r.Context(), listId)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			writeConflict(w, current, current.Version)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "unknown list", 404)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+// copyList creates a new list containing the items of the list. The body contains the mode, either "all" (the
+// default) or "unchecked", and optionally the details of the new list.
+func copyList(listService *services.ListService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listId := r.PathValue("listId")
+		var body struct {
+			listDetails
+			Mode services.CopyMode `json:"mode"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if body.Mode == "" {
+			body.Mode = services.CopyAll
+		}
+
+		list, err := listService.Copy(r.Context(), listId, body.Mode, body.toService(nil))
+		if errors.Is(err, services.ErrInvalidList) {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "unknown list", 404)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(list)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+// getItemsByListId returns the items of the list in their manual order, or, if the query parameter layout is set,
+// sorted by the store layout with this id.
+func getItemsByListId(itemService *services.ItemService, categoryService *services.CategoryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("listId")
+		items, err := itemService.FindAllByListId(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if layoutId := r.URL.Query().Get("layout"); layoutId != "" {
+			layout, err := categoryService.FindLayoutById(r.Context(), layoutId)
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "unknown store layout", 404)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			services.SortByStoreLayout(items, layout)
+		}
+		err = json.NewEncoder(w).Encode(items)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+func createItemForListId(itemService *services.ItemService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listId := r.PathValue("listId")
+		type NewShoppingListItem struct {
+			Text     string   `json:"text"`
+			Quantity *float64 `json:"quantity"`
+			Unit     *string  `json:"unit"`
+			Category *string  `json:"category"`
+			After    *string  `json:"after"`
+		}
+		var newItem NewShoppingListItem
+		json.NewDecoder(r.Body).Decode(&newItem)
+		if newItem.Quantity != nil && *newItem.Quantity <= 0 {
+			http.Error(w, "quantity must be positive", 400)
+			return
+		}
+
+		item, err := itemService.Create(r.Context(), listId, services.NewItem{
+			Text:     newItem.Text,
+			Quantity: newItem.Quantity,
+			Unit:     newItem.Unit,
+			Category: newItem.Category,
+			After:    newItem.After,
+		})
+		if errors.Is(err, services.ErrUnknownCategory) {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(item)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+func getItemById(itemService *services.ItemService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		itemId := r.PathValue("itemId")
+		item, err := itemService.FindById(r.Context(), itemId)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		setETag(w, item.Version)
+		err = json.NewEncoder(w).Encode(item)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+func updateItemById(itemService *services.ItemService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		itemId := r.PathValue("itemId")
+		patch := struct {
+			Text     *string  `json:"text"`
+			Checked  *bool    `json:"checked"`
+			Quantity *float64 `json:"quantity"`
+			Unit     *string  `json:"unit"`
+			Category *string  `json:"category"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&patch)
+		if patch.Quantity != nil && *patch.Quantity <= 0 {
+			http.Error(w, "quantity must be positive", 400)
+			return
+		}
+		ifVersion, err := parseIfMatch(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		item, err := itemService.UpdateById(r.Context(), itemId, services.ItemChanges{
+			Text:      patch.Text,
+			Checked:   patch.Checked,
+			Quantity:  patch.Quantity,
+			Unit:      patch.Unit,
+			Category:  patch.Category,
+			IfVersion: ifVersion,
+		})
+		if errors.Is(err, services.ErrVersionConflict) {
+			writeItemConflict(w, r, itemService, itemId)
+			return
+		}
+		if errors.Is(err, services.ErrUnknownCategory) {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		setETag(w, item.Version)
+		err = json.NewEncoder(w).Encode(item)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+func deleteItemById(itemService *services.ItemService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		itemId := r.PathValue("itemId")
+		ifVersion, err := parseIfMatch(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		err = itemService.DeleteById(r.Context(), itemId, ifVersion)
+		if errors.Is(err, services.ErrVersionConflict) {
+			writeItemConflict(w, r, itemService, itemId)
+			return
+		}
+		if err != nil {
+			slog.Info("we got err", "err", err)
+			http.Error(w, "bad", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func moveItemById(itemService *services.ItemService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		itemId := r.PathValue("itemId")
+		ids := struct {
+			AfterId  *string `json:"afterId"` // id after which the item should be inserted
+			ParentId *string `json:"parentId"`
+		}{}
+		err := json.NewDecoder(r.Body).Decode(&ids)
+		if err != nil {
+			slog.Info("we got err", "err", err)
+			http.Error(w, "", http.StatusInternalServerError)
+			return
+		}
+		ifVersion, err := parseIfMatch(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		err = itemService.MoveById(r.Context(), itemId, services.MoveInstructions{
+			AfterId:   ids.AfterId,
+			ParentId:  ids.ParentId,
+			IfVersion: ifVersion,
+		})
+		if errors.Is(err, services.ErrVersionConflict) {
+			writeItemConflict(w, r, itemService, itemId)
+			return
+		}
+		if err != nil {
+			slog.Info("we got err", "err", err)
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+func syncOperations(syncService *services.SyncService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			DeviceID   string                        `json:"deviceId"`
+			Since      *int64                        `json:"since"`
+			Operations []services.SyncOperationInput `json:"operations"`
+		}{}
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		result, err := syncService.Sync(r.Context(), body.DeviceID, body.Since, body.Operations)
+		if errors.Is(err, services.ErrInvalidSync) {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if err != nil {
+			slog.Info("failed to sync operations", "err", err)
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(result)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+// mergeDuplicates merges duplicate items of the list. If preview is set, the merges are only returned, but not applied.
+func mergeDuplicates(itemService *services.ItemService, preview bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listId := r.PathValue("listId")
+		var merges []services.DuplicateMerge
+		var err error
+		if preview {
+			merges, err = itemService.PreviewMergeDuplicates(r.Context(), listId)
+		} else {
+			merges, err = itemService.MergeDuplicates(r.Context(), listId)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(merges)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+func getListViewers(hub *events.EventHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listId := r.PathValue("listId")
+		err := json.NewEncoder(w).Encode(hub.Viewers(listId))
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+func getAllCategories(categoryService *services.CategoryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		categories, err := categoryService.GetAll(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(categories)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+func createCategory(categoryService *services.CategoryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var newCategory struct {
+			Name string `json:"name"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&newCategory)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if strings.TrimSpace(newCategory.Name) == "" {
+			http.Error(w, "name must not be empty", 400)
+			return
+		}
+
+		category, err := categoryService.Create(r.Context(), newCategory.Name)
+		if errors.Is(err, services.ErrDuplicateCategory) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(category)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+func deleteCategory(categoryService *services.CategoryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := categoryService.Delete(r.Context(), r.PathValue("categoryId"))
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "unknown category", 404)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+func getAllStoreLayouts(categoryService *services.CategoryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		layouts, err := categoryService.GetAllLayouts(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(layouts)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+// saveStoreLayout creates a store layout, or, if the path contains a layoutId, replaces the store layout with this id.
+func saveStoreLayout(categoryService *services.CategoryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var storeLayout struct {
+			Name       string   `json:"name"`
+			Categories []string `json:"categories"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&storeLayout)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		var layout db.StoreLayout
+		if layoutId := r.PathValue("layoutId"); layoutId != "" {
+			layout, err = categoryService.UpdateLayout(r.Context(), layoutId, storeLayout.Name, storeLayout.Categories)
+		} else {
+			layout, err = categoryService.CreateLayout(r.Context(), storeLayout.Name, storeLayout.Categories)
+		}
+		if errors.Is(err, services.ErrInvalidStoreLayout) {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "unknown store layout", 404)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(layout)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+func deleteStoreLayout(categoryService *services.CategoryService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := categoryService.DeleteLayout(r.Context(), r.PathValue("layoutId"))
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "unknown store layout", 404)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+func getAllTemplates(templateService *services.TemplateService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		templates, err := templateService.GetAll(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(templates)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
+
+func getTemplate(templateService *services.TemplateService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		template, err := templateService.FindById(r.Context(), r.PathValue("templateId"))
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "unknown template", 404)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(template)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
-		err = json.NewEncoder(w).Encode(lists)
+	}
+}
+
+// saveTemplate creates a template, or, if the path contains a templateId, replaces the template with this id.
+func saveTemplate(templateService *services.TemplateService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Name  string            `json:"name"`
+			Items []db.TemplateItem `json:"items"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		var template db.Template
+		if templateId := r.PathValue("templateId"); templateId != "" {
+			template, err = templateService.Update(r.Context(), templateId, body.Name, body.Items)
+		} else {
+			template, err = templateService.Create(r.Context(), body.Name, body.Items)
+		}
+		if errors.Is(err, services.ErrInvalidTemplate) {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "unknown template", 404)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(template)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -45,14 +744,46 @@ func getAllLists(listService *services.ListService) http.HandlerFunc {
 	}
 }
 
-func createList(listService *services.ListService) http.HandlerFunc {
+func deleteTemplate(templateService *services.TemplateService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		list, err := listService.Create(r.Context())
+		err := templateService.Delete(r.Context(), r.PathValue("templateId"))
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "unknown template", 404)
+			return
+		}
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
-		err = json.NewEncoder(w).Encode(list)
+	}
+}
+
+// saveListAsTemplate creates a template with the given name, containing the items of the list.
+func saveListAsTemplate(templateService *services.TemplateService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Name string `json:"name"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		template, err := templateService.CreateFromList(r.Context(), r.PathValue("listId"), body.Name)
+		if errors.Is(err, services.ErrInvalidTemplate) {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "unknown list", 404)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(template)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -60,15 +791,14 @@ func createList(listService *services.ListService) http.HandlerFunc {
 	}
 }
 
-func getList(listService *services.ListService) http.HandlerFunc {
+func getAllRecurrences(recurrenceService *services.RecurrenceService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id := r.PathValue("listId")
-		list, err := listService.FindById(r.Context(), id)
+		recurrences, err := recurrenceService.GetAll(r.Context())
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
-		err = json.NewEncoder(w).Encode(list)
+		err = json.NewEncoder(w).Encode(recurrences)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -76,30 +806,44 @@ func getList(listService *services.ListService) http.HandlerFunc {
 	}
 }
 
-func updateList(listService *services.ListService) http.HandlerFunc {
+func createRecurrence(recurrenceService *services.RecurrenceService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		listId := r.PathValue("listId")
-		var updateListStatus struct {
-			Status string `json:"status"`
+		var body struct {
+			Title     string  `json:"title"`
+			Frequency string  `json:"frequency"`
+			Start     string  `json:"start"`
+			Template  *string `json:"template"`
 		}
-		err := json.NewDecoder(r.Body).Decode(&updateListStatus)
+		err := json.NewDecoder(r.Body).Decode(&body)
 		if err != nil {
 			http.Error(w, err.Error(), 400)
+			return
 		}
 
-		status := updateListStatus.Status
-		validStatus := slices.Contains(([]string{"inprogress", "todo", "done"}), status)
-		if !validStatus {
-			http.Error(w, "invalid status", 400)
+		recurrence, err := recurrenceService.Create(r.Context(), body.Title, body.Frequency, body.Start, body.Template)
+		if errors.Is(err, services.ErrInvalidRecurrence) {
+			http.Error(w, err.Error(), 400)
 			return
 		}
-
-		list, err := listService.Update(r.Context(), listId, status)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(recurrence)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
 		}
+	}
+}
 
-		err = json.NewEncoder(w).Encode(list)
+func deleteRecurrence(recurrenceService *services.RecurrenceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := recurrenceService.Delete(r.Context(), r.PathValue("recurrenceId"))
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "unknown recurrence", 404)
+			return
+		}
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -107,15 +851,26 @@ func updateList(listService *services.ListService) http.HandlerFunc {
 	}
 }
 
-func getItemsByListId(itemService *services.ItemService) http.HandlerFunc {
+// getSuggestions returns items used on previous lists that match the query parameter q, to offer completions. The
+// number of suggestions can be set using the query parameter limit.
+func getSuggestions(suggestionService *services.SuggestionService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id := r.PathValue("listId")
-		items, err := itemService.FindAllByListId(r.Context(), id)
+		limit := 10
+		if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+			var err error
+			limit, err = strconv.Atoi(rawLimit)
+			if err != nil || limit < 1 || limit > 100 {
+				http.Error(w, "limit must be a number between 1 and 100", 400)
+				return
+			}
+		}
+
+		suggestions, err := suggestionService.Suggest(r.Context(), r.URL.Query().Get("q"), limit)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
-		err = json.NewEncoder(w).Encode(items)
+		err = json.NewEncoder(w).Encode(suggestions)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -123,71 +878,121 @@ func getItemsByListId(itemService *services.ItemService) http.HandlerFunc {
 	}
 }
 
-func createItemForListId(itemService *services.ItemService) http.HandlerFunc {
+// getDueItems returns items that are bought regularly and are probably due on the day of the list.
+func getDueItems(suggestionService *services.SuggestionService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		listId := r.PathValue("listId")
-		type NewShoppingListItem struct {
-			Text  string  `json:"text"`
-			After *string `json:"after"`
+		dueItems, err := suggestionService.ProbablyDue(r.Context(), r.PathValue("listId"))
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "unknown list", 404)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(dueItems)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
 		}
-		var newItem NewShoppingListItem
-		json.NewDecoder(r.Body).Decode(&newItem)
-
-		itemService.Create(r.Context(), listId, newItem.Text, newItem.After)
 	}
 }
 
-func updateItemById(itemService *services.ItemService) http.HandlerFunc {
+// search returns the items and lists of all lists matching the query parameter q. The number of results can be set
+// using the query parameter limit.
+func search(searchService *services.SearchService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		itemId := r.PathValue("itemId")
-		patch := struct {
-			Text    *string `json:"text"`
-			Checked *bool   `json:"checked"`
-		}{}
-		json.NewDecoder(r.Body).Decode(&patch)
+		limit := 20
+		if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+			var err error
+			limit, err = strconv.Atoi(rawLimit)
+			if err != nil || limit < 1 || limit > 100 {
+				http.Error(w, "limit must be a number between 1 and 100", 400)
+				return
+			}
+		}
+
+		result, err := searchService.Search(r.Context(), r.URL.Query().Get("q"), limit)
+		if errors.Is(err, services.ErrInvalidSearch) {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(result)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+}
 
-		itemService.UpdateById(r.Context(), itemId, patch.Text, patch.Checked)
+// parseHistoryLimit returns the number of history entries requested using the query parameter limit.
+func parseHistoryLimit(r *http.Request) (int, error) {
+	rawLimit := r.URL.Query().Get("limit")
+	if rawLimit == "" {
+		return 100, nil
+	}
+	limit, err := strconv.Atoi(rawLimit)
+	if err != nil || limit < 1 || limit > 1000 {
+		return 0, fmt.Errorf("limit must be a number between 1 and 1000")
 	}
+	return limit, nil
 }
 
-func deleteItemById(itemService *services.ItemService) http.HandlerFunc {
+// getListHistory returns the changes of the items of the list, newest first.
+func getListHistory(itemService *services.ItemService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		itemId := r.PathValue("itemId")
-
-		err := itemService.DeleteById(r.Context(), itemId)
+		limit, err := parseHistoryLimit(r)
 		if err != nil {
-			slog.Info("we got err", "err", err)
-			http.Error(w, "bad", http.StatusInternalServerError)
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		entries, err := itemService.FindHistoryByListId(r.Context(), r.PathValue("listId"), limit)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "unknown list", 404)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = json.NewEncoder(w).Encode(entries)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
 			return
 		}
 	}
 }
 
-func moveItemById(itemService *services.ItemService) http.HandlerFunc {
+// getItemHistory returns the changes of the item, newest first. It also works for deleted items.
+func getItemHistory(itemService *services.ItemService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		itemId := r.PathValue("itemId")
-		ids := struct {
-			AfterId  *string `json:"afterId"` // id after which the item should be inserted
-			ParentId *string `json:"parentId"`
-		}{}
-		err := json.NewDecoder(r.Body).Decode(&ids)
+		limit, err := parseHistoryLimit(r)
 		if err != nil {
-			slog.Info("we got err", "err", err)
-			http.Error(w, "", http.StatusInternalServerError)
+			http.Error(w, err.Error(), 400)
 			return
 		}
-
-		err = itemService.MoveById(r.Context(), itemId, services.MoveInstructions{
-			AfterId:  ids.AfterId,
-			ParentId: ids.ParentId,
-		})
+		entries, err := itemService.FindHistoryByItemId(r.Context(), r.PathValue("itemId"), limit)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		entries = slices.DeleteFunc(entries, func(entry db.ItemHistoryEntry) bool { return entry.List != r.PathValue("listId") })
+		if len(entries) == 0 {
+			http.Error(w, "unknown item", 404)
+			return
+		}
+		err = json.NewEncoder(w).Encode(entries)
 		if err != nil {
-			slog.Info("we got err", "err", err)
 			http.Error(w, err.Error(), 500)
 			return
 		}
 	}
 }
+
 func login(userRepo *db.UserRepository, sessionRepo *db.SessionRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := r.ParseForm()
@@ -242,38 +1047,129 @@ func loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func serve(ctx context.Context, useDirFS bool) error {
+// openDatabase opens the database, and applies all schema files that were not applied yet.
+func openDatabase(ctx context.Context) (*sql.DB, error) {
+	dbConn, err := sql.Open("sqlite3", "db.sqlite?_foreign_keys=true&_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open db %w", err)
+	}
+
+	err = db.EnsureFTS5(ctx, dbConn)
+	if err != nil {
+		dbConn.Close()
+		return nil, err
+	}
+
+	err = db.EnsureUpToDateSchema(embedSchemaFS, dbConn, ctx)
+	if err != nil {
+		dbConn.Close()
+		return nil, fmt.Errorf("failed to ensure that schema is updated: %w", err)
+	}
+
+	err = db.NewItemRepository(dbConn).FillNormalizedTexts(ctx)
+	if err != nil {
+		dbConn.Close()
+		return nil, err
+	}
+	return dbConn, nil
+}
+
+type serveOptions struct {
+	address  string
+	useDirFS bool
+	// eventBus is either "memory" or "sqlite"
+	eventBus             string
+	eventBusPollInterval time.Duration
+	// archiveRetention is how long archived lists are kept before they are purged; 0 keeps them forever
+	archiveRetention time.Duration
+	// metricsAddress, if set, is an additional address /metrics is served on without authentication, e.g. for
+	// scraping from an internal network
+	metricsAddress string
+}
+
+func serve(ctx context.Context, opts serveOptions) error {
 	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
 	r := http.NewServeMux()
 
-	dbConn, err := sql.Open("sqlite3", "db.sqlite?_foreign_keys=true")
+	dbConn, err := openDatabase(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to open db %w", err)
+		return err
 	}
 	defer func() {
 		slog.Info("closing database connection")
 		dbConn.Close()
 	}()
 
-	err = db.EnsureUpToDateSchema(embedSchemaFS, dbConn, ctx)
-	if err != nil {
-		return fmt.Errorf("failed to ensure that schema is updated: %w", err)
-	}
+	eventRepo := db.NewEventRepository(dbConn)
+	userRepo := db.NewUserRepository(dbConn)
+	hub := events.New(eventRepo, userRepo)
 
-	hub := events.New()
+	var eventBus events.EventBus
+	switch opts.eventBus {
+	case "memory":
+		eventBus = hub
+	case "sqlite":
+		sqliteEventBus := events.NewSQLiteEventBus(hub, eventRepo, opts.eventBusPollInterval)
+		go func() {
+			err := sqliteEventBus.Run(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				slog.Error("sqlite event bus stopped", "err", err)
+			}
+		}()
+		eventBus = sqliteEventBus
+	default:
+		return fmt.Errorf("unknown event bus %s", opts.eventBus)
+	}
 
 	listRepo := db.NewListRepository(dbConn)
 	itemRepo := db.NewItemRepository(dbConn)
 	sessionRepo := db.NewSessionRepository(dbConn)
-	userRepo := db.NewUserRepository(dbConn)
+	webhookRepo := db.NewWebhookRepository(dbConn)
+	syncRepo := db.NewSyncOperationRepository(dbConn)
+	categoryRepo := db.NewCategoryRepository(dbConn)
+	storeLayoutRepo := db.NewStoreLayoutRepository(dbConn)
+	templateRepo := db.NewTemplateRepository(dbConn)
+	recurrenceRepo := db.NewRecurrenceRepository(dbConn)
+	historyRepo := db.NewItemHistoryRepository(dbConn)
+
+	listService := services.NewListService(dbConn, listRepo, itemRepo, categoryRepo, storeLayoutRepo, templateRepo, historyRepo, eventBus)
+	itemService := services.NewItemRepository(dbConn, listRepo, itemRepo, categoryRepo, historyRepo, eventBus)
+	categoryService := services.NewCategoryService(dbConn, categoryRepo, storeLayoutRepo)
+	templateService := services.NewTemplateService(dbConn, templateRepo, listRepo, itemRepo, categoryRepo)
+	recurrenceService := services.NewRecurrenceService(recurrenceRepo, templateRepo, listService)
+	suggestionService := services.NewSuggestionService(listRepo, itemRepo)
+	searchService := services.NewSearchService(listRepo, itemRepo)
+	commandDispatcher := services.NewCommandDispatcher(listService, itemService)
+	syncService := services.NewSyncService(itemService, listService, itemRepo, syncRepo, eventRepo, hub)
+
+	webhookWorker := webhooks.NewWorker(hub, eventRepo, webhookRepo)
+	go func() {
+		err := webhookWorker.Run(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			slog.Error("webhook worker stopped", "err", err)
+		}
+	}()
+
+	if opts.archiveRetention > 0 {
+		go func() {
+			err := listService.RunRetention(ctx, opts.archiveRetention, time.Hour)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				slog.Error("archive retention stopped", "err", err)
+			}
+		}()
+	}
 
-	listService := services.NewListService(listRepo, hub)
-	itemService := services.NewItemRepository(listRepo, itemRepo, hub)
+	go func() {
+		err := recurrenceService.Run(ctx, time.Minute)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			slog.Error("recurrence scheduler stopped", "err", err)
+		}
+	}()
 
 	var fileServer http.Handler
-	if useDirFS {
+	if opts.useDirFS {
 		slog.Info("Serving files from directory")
 		filesDir := os.DirFS("./frontend/dist")
 		fileServer = http.FileServer(http.FS(filesDir))
@@ -290,13 +1186,41 @@ func serve(ctx context.Context, useDirFS bool) error {
 	fsRouter.Handle("GET /", fileServer)
 
 	apiRouter := http.NewServeMux()
-	apiRouter.Handle("GET /api/events/", events.EstablishConnection(hub))
+	apiRouter.Handle("GET /api/events/", events.EstablishConnection(hub, commandDispatcher))
+	apiRouter.Handle("POST /api/sync", syncOperations(syncService))
 	apiRouter.Handle("GET /api/list/", getAllLists(listService))
 	apiRouter.Handle("POST /api/list/", createList(listService))
 	apiRouter.Handle("GET /api/list/{listId}/", getList(listService))
 	apiRouter.Handle("PATCH /api/list/{listId}/", updateList(listService))
-	apiRouter.Handle("GET /api/list/{listId}/item/", getItemsByListId(itemService))
+	apiRouter.Handle("DELETE /api/list/{listId}/", deleteList(listService))
+	apiRouter.Handle("POST /api/list/{listId}/copy", copyList(listService))
+	apiRouter.Handle("GET /api/list/{listId}/viewers", getListViewers(hub))
+	apiRouter.Handle("GET /api/category/", getAllCategories(categoryService))
+	apiRouter.Handle("POST /api/category/", createCategory(categoryService))
+	apiRouter.Handle("DELETE /api/category/{categoryId}", deleteCategory(categoryService))
+	apiRouter.Handle("GET /api/store-layout/", getAllStoreLayouts(categoryService))
+	apiRouter.Handle("POST /api/store-layout/", saveStoreLayout(categoryService))
+	apiRouter.Handle("PUT /api/store-layout/{layoutId}", saveStoreLayout(categoryService))
+	apiRouter.Handle("DELETE /api/store-layout/{layoutId}", deleteStoreLayout(categoryService))
+	apiRouter.Handle("GET /api/template/", getAllTemplates(templateService))
+	apiRouter.Handle("POST /api/template/", saveTemplate(templateService))
+	apiRouter.Handle("GET /api/template/{templateId}", getTemplate(templateService))
+	apiRouter.Handle("PUT /api/template/{templateId}", saveTemplate(templateService))
+	apiRouter.Handle("DELETE /api/template/{templateId}", deleteTemplate(templateService))
+	apiRouter.Handle("GET /api/search", search(searchService))
+	apiRouter.Handle("GET /api/suggestions", getSuggestions(suggestionService))
+	apiRouter.Handle("GET /api/recurrence/", getAllRecurrences(recurrenceService))
+	apiRouter.Handle("POST /api/recurrence/", createRecurrence(recurrenceService))
+	apiRouter.Handle("DELETE /api/recurrence/{recurrenceId}", deleteRecurrence(recurrenceService))
+	apiRouter.Handle("POST /api/list/{listId}/template", saveListAsTemplate(templateService))
+	apiRouter.Handle("GET /api/list/{listId}/due-items", getDueItems(suggestionService))
+	apiRouter.Handle("GET /api/list/{listId}/merge-duplicates", mergeDuplicates(itemService, true))
+	apiRouter.Handle("POST /api/list/{listId}/merge-duplicates", mergeDuplicates(itemService, false))
+	apiRouter.Handle("GET /api/list/{listId}/history", getListHistory(itemService))
+	apiRouter.Handle("GET /api/list/{listId}/item/{itemId}/history", getItemHistory(itemService))
+	apiRouter.Handle("GET /api/list/{listId}/item/", getItemsByListId(itemService, categoryService))
 	apiRouter.Handle("POST /api/list/{listId}/item/", createItemForListId(itemService))
+	apiRouter.Handle("GET /api/list/{listId}/item/{itemId}", getItemById(itemService))
 	apiRouter.Handle("PATCH /api/list/{listId}/item/{itemId}", updateItemById(itemService))
 	apiRouter.Handle("DELETE /api/list/{listId}/item/{itemId}", deleteItemById(itemService))
 	apiRouter.Handle("POST /api/list/{listId}/item/{itemId}/move", moveItemById(itemService))
@@ -304,13 +1228,14 @@ func serve(ctx context.Context, useDirFS bool) error {
 	r.Handle("/", fsRouter)
 	r.Handle("/api/", auth.EnsureSessionAuthMiddleware(apiRouter, sessionRepo))
 	r.Handle("POST /login", login(userRepo, sessionRepo))
+	r.Handle("GET /metrics", auth.EnsureSessionAuthMiddleware(events.MetricsHandler(hub), sessionRepo))
 
-	slog.Info("Application ready!", "address", "http://localhost:3333")
+	slog.Info("Application ready!", "address", opts.address)
 
 	handler := loggingMiddleware(r)
 	handler = session.SessionMiddleware(handler, sessionRepo)
 
-	server := &http.Server{Addr: "0.0.0.0:3333", Handler: handler}
+	server := &http.Server{Addr: opts.address, Handler: handler}
 
 	ctx, cancel := context.WithCancelCause(ctx)
 	go func() {
@@ -320,6 +1245,19 @@ func serve(ctx context.Context, useDirFS bool) error {
 		}
 	}()
 
+	if opts.metricsAddress != "" {
+		metricsRouter := http.NewServeMux()
+		metricsRouter.Handle("GET /metrics", events.MetricsHandler(hub))
+		metricsServer := &http.Server{Addr: opts.metricsAddress, Handler: metricsRouter}
+		slog.Info("Serving metrics", "address", opts.metricsAddress)
+		go func() {
+			err := metricsServer.ListenAndServe()
+			if err != nil {
+				cancel(err)
+			}
+		}()
+	}
+
 	<-ctx.Done()
 	slog.Error("context done", "err", context.Cause(ctx))
 	return ctx.Err()
@@ -333,14 +1271,43 @@ func main() {
 			{
 				Name: "serve",
 				Action: func(ctx context.Context, c *cli.Command) error {
-					useDirFS := c.Bool("dirFS")
-					return serve(ctx, useDirFS)
+					return serve(ctx, serveOptions{
+						address:              c.String("address"),
+						useDirFS:             c.Bool("dirFS"),
+						eventBus:             c.String("eventBus"),
+						eventBusPollInterval: c.Duration("eventBusPollInterval"),
+						archiveRetention:     c.Duration("archiveRetention"),
+						metricsAddress:       c.String("metricsAddress"),
+					})
 				},
 				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "address",
+						Value: "0.0.0.0:3333",
+					},
 					&cli.BoolFlag{
 						Name:  "dirFS",
 						Value: false,
 					},
+					&cli.StringFlag{
+						Name:  "eventBus",
+						Usage: "how events are distributed: \"memory\" for a single instance, \"sqlite\" for multiple instances sharing the database",
+						Value: "memory",
+					},
+					&cli.DurationFlag{
+						Name:  "eventBusPollInterval",
+						Usage: "how often the sqlite event bus polls for new events",
+						Value: 500 * time.Millisecond,
+					},
+					&cli.DurationFlag{
+						Name:  "archiveRetention",
+						Usage: "how long archived lists are kept before they are deleted; 0 keeps them forever",
+						Value: 30 * 24 * time.Hour,
+					},
+					&cli.StringFlag{
+						Name:  "metricsAddress",
+						Usage: "additional address to serve /metrics on without authentication, e.g. 127.0.0.1:9090; empty disables it",
+					},
 				},
 			},
 			{
@@ -392,6 +1359,162 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:  "list",
+				Usage: "maintain shopping lists",
+				Commands: []*cli.Command{
+					{
+						Name:      "renormalize",
+						Usage:     "reset the sort keys of all items of a list, keeping their order; connected clients are only notified by servers using --eventBus sqlite",
+						ArgsUsage: "<listId>",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							listId := c.Args().First()
+							if listId == "" {
+								return fmt.Errorf("listId is required")
+							}
+
+							dbConn, err := openDatabase(ctx)
+							if err != nil {
+								return err
+							}
+							defer dbConn.Close()
+							eventRepo := db.NewEventRepository(dbConn)
+							hub := events.New(eventRepo, db.NewUserRepository(dbConn))
+							// only stores the events in the event log. Running servers only pick them up from there if they
+							// were started with --eventBus sqlite; servers using the in-memory event bus do not notice them
+							eventBus := events.NewSQLiteEventBus(hub, eventRepo, 0)
+							itemService := services.NewItemRepository(dbConn, db.NewListRepository(dbConn), db.NewItemRepository(dbConn), db.NewCategoryRepository(dbConn), db.NewItemHistoryRepository(dbConn), eventBus)
+
+							changed, err := itemService.RenormalizeList(ctx, listId)
+							if err != nil {
+								return fmt.Errorf("failed to renormalize list: %w", err)
+							}
+							fmt.Printf("renormalized %d items\n", changed)
+							return nil
+						},
+					},
+				},
+			},
+			{
+				Name:  "webhook",
+				Usage: "manage webhooks, which are called for every event",
+				Commands: []*cli.Command{
+					{
+						Name: "add",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "url",
+								Required: true,
+							},
+							&cli.StringSliceFlag{
+								Name:  "event",
+								Usage: "event type the webhook is called for; can be repeated. If not set, the webhook is called for all events",
+							},
+						},
+						Action: func(ctx context.Context, c *cli.Command) error {
+							secret, err := webhooks.GenerateSecret()
+							if err != nil {
+								return fmt.Errorf("failed to generate secret: %w", err)
+							}
+
+							dbConn, err := openDatabase(ctx)
+							if err != nil {
+								return err
+							}
+							defer dbConn.Close()
+							webhookRepo := db.NewWebhookRepository(dbConn)
+							webhook, err := webhookRepo.Create(ctx, c.String("url"), secret, c.StringSlice("event"))
+							if err != nil {
+								return fmt.Errorf("failed to create webhook: %w", err)
+							}
+
+							fmt.Printf("id: %s\nsecret: %s\n", webhook.ID, webhook.Secret)
+							return nil
+						},
+					},
+					{
+						Name: "list",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							dbConn, err := openDatabase(ctx)
+							if err != nil {
+								return err
+							}
+							defer dbConn.Close()
+							webhookRepo := db.NewWebhookRepository(dbConn)
+							allWebhooks, err := webhookRepo.FindAll(ctx)
+							if err != nil {
+								return fmt.Errorf("failed to find webhooks: %w", err)
+							}
+
+							for _, webhook := range allWebhooks {
+								eventTypes := "all events"
+								if len(webhook.EventTypes) > 0 {
+									eventTypes = strings.Join(webhook.EventTypes, ",")
+								}
+								fmt.Printf("%s\t%s\t%s\n", webhook.ID, webhook.URL, eventTypes)
+							}
+							return nil
+						},
+					},
+					{
+						Name:      "remove",
+						ArgsUsage: "<id>",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							id := c.Args().First()
+							if id == "" {
+								return fmt.Errorf("id of the webhook is required")
+							}
+
+							dbConn, err := openDatabase(ctx)
+							if err != nil {
+								return err
+							}
+							defer dbConn.Close()
+							webhookRepo := db.NewWebhookRepository(dbConn)
+							err = webhookRepo.Delete(ctx, id)
+							if err != nil {
+								return fmt.Errorf("failed to remove webhook %s: %w", id, err)
+							}
+							return nil
+						},
+					},
+					{
+						Name:      "deliveries",
+						Usage:     "show the most recent delivery attempts of a webhook",
+						ArgsUsage: "<id>",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							id := c.Args().First()
+							if id == "" {
+								return fmt.Errorf("id of the webhook is required")
+							}
+
+							dbConn, err := openDatabase(ctx)
+							if err != nil {
+								return err
+							}
+							defer dbConn.Close()
+							webhookRepo := db.NewWebhookRepository(dbConn)
+							deliveries, err := webhookRepo.FindDeliveries(ctx, id, 50)
+							if err != nil {
+								return fmt.Errorf("failed to find deliveries: %w", err)
+							}
+
+							for _, delivery := range deliveries {
+								responseStatus := "-"
+								if delivery.ResponseStatus != nil {
+									responseStatus = strconv.Itoa(*delivery.ResponseStatus)
+								}
+								deliveryErr := ""
+								if delivery.Error != nil {
+									deliveryErr = *delivery.Error
+								}
+								fmt.Printf("%s\tseq=%d\tattempt=%d\t%s\t%s\t%s\n", delivery.Date, delivery.EventSeq, delivery.Attempt, delivery.Status, responseStatus, deliveryErr)
+							}
+							return nil
+						},
+					},
+				},
+			},
 		},
 		DefaultCommand: "serve",
 	}
@@ -0,0 +1,7 @@
+CREATE TABLE events (
+    seq     integer PRIMARY KEY AUTOINCREMENT,
+    type    text                NOT NULL,
+    list    text,
+    payload text                NOT NULL,
+    date    text                NOT NULL
+);
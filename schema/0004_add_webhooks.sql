@@ -0,0 +1,20 @@
+CREATE TABLE webhooks (
+    id          text    PRIMARY KEY NOT NULL,
+    url         text                NOT NULL,
+    secret      text                NOT NULL,
+    eventTypes  text                NOT NULL DEFAULT "[]",
+    createdAt   text                NOT NULL
+);
+
+CREATE TABLE webhook_deliveries (
+    id              integer PRIMARY KEY NOT NULL,
+    webhook         text                NOT NULL,
+    eventSeq        integer             NOT NULL,
+    attempt         integer             NOT NULL,
+    status          text                NOT NULL,
+    responseStatus  integer,
+    error           text,
+    date            text                NOT NULL,
+    FOREIGN KEY (webhook) REFERENCES webhooks (id) ON DELETE CASCADE,
+    UNIQUE (webhook, eventSeq, attempt)
+);
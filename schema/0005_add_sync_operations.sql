@@ -0,0 +1,13 @@
+CREATE TABLE sync_operations (
+    clientId        text    PRIMARY KEY NOT NULL,
+    type            text                NOT NULL,
+    status          text                NOT NULL,
+    clientItemId    text,
+    itemId          text,
+    error           text,
+    clientTimestamp text                NOT NULL,
+    date            text                NOT NULL
+);
+
+CREATE INDEX sync_operations_client_item_id_index
+    ON sync_operations(clientItemId);
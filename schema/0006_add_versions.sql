@@ -0,0 +1,3 @@
+ALTER TABLE items ADD COLUMN version integer NOT NULL DEFAULT 1;
+
+ALTER TABLE lists ADD COLUMN version integer NOT NULL DEFAULT 1;
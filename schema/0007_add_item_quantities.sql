@@ -0,0 +1,3 @@
+ALTER TABLE items ADD COLUMN quantity real;
+
+ALTER TABLE items ADD COLUMN unit text;
@@ -0,0 +1,28 @@
+CREATE TABLE categories (
+    id      text    PRIMARY KEY NOT NULL,
+    name    text                NOT NULL UNIQUE
+);
+
+ALTER TABLE items ADD COLUMN category text REFERENCES categories (id) ON DELETE SET NULL;
+
+-- the category last assigned to an item with the given (normalised) text; used to assign categories automatically
+CREATE TABLE category_assignments (
+    text        text    PRIMARY KEY NOT NULL,
+    category    text                NOT NULL,
+    date        text                NOT NULL,
+    FOREIGN KEY (category) REFERENCES categories (id) ON DELETE CASCADE
+);
+
+CREATE TABLE store_layouts (
+    id      text    PRIMARY KEY NOT NULL,
+    name    text                NOT NULL UNIQUE
+);
+
+CREATE TABLE store_layout_categories (
+    layout      text    NOT NULL,
+    category    text    NOT NULL,
+    position    integer NOT NULL,
+    PRIMARY KEY (layout, category),
+    FOREIGN KEY (layout) REFERENCES store_layouts (id) ON DELETE CASCADE,
+    FOREIGN KEY (category) REFERENCES categories (id) ON DELETE CASCADE
+);
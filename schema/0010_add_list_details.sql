@@ -0,0 +1,8 @@
+ALTER TABLE lists ADD COLUMN title text NOT NULL DEFAULT '';
+
+ALTER TABLE lists ADD COLUMN notes text;
+
+-- the day the shopping trip is planned for, formatted as YYYY-MM-DD
+ALTER TABLE lists ADD COLUMN plannedDate text;
+
+ALTER TABLE lists ADD COLUMN store text REFERENCES store_layouts (id) ON DELETE SET NULL;
@@ -0,0 +1,2 @@
+-- the time the list was archived; archived lists are hidden by default, and purged after a while
+ALTER TABLE lists ADD COLUMN archivedAt text;
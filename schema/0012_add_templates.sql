@@ -0,0 +1,23 @@
+-- templates are reusable trees of items, e.g. weekly staples, that lists can be created from
+CREATE TABLE templates (
+    id      text    PRIMARY KEY NOT NULL,
+    name    text                NOT NULL UNIQUE
+);
+
+CREATE TABLE template_items (
+    id          text    PRIMARY KEY NOT NULL,
+    template    text                NOT NULL,
+    parent      text,
+    position    integer             NOT NULL,
+    text        text                NOT NULL,
+    quantity    real,
+    unit        text,
+    note        text,
+    category    text,
+    FOREIGN KEY (template) REFERENCES templates (id) ON DELETE CASCADE,
+    FOREIGN KEY (parent) REFERENCES template_items (id) ON DELETE CASCADE,
+    FOREIGN KEY (category) REFERENCES categories (id) ON DELETE SET NULL
+);
+
+CREATE INDEX template_items_template_index
+    ON template_items(template);
@@ -0,0 +1,14 @@
+-- recurrences create lists on a schedule, from a template or from the unchecked items of the list they created last
+CREATE TABLE recurrences (
+    id          text    PRIMARY KEY NOT NULL,
+    title       text                NOT NULL DEFAULT "",
+    -- weekly, biweekly or monthly
+    frequency   text                NOT NULL,
+    -- the first run; later runs are counted from it
+    start       text                NOT NULL,
+    nextRun     text                NOT NULL,
+    template    text,
+    lastList    text,
+    FOREIGN KEY (template) REFERENCES templates (id) ON DELETE SET NULL,
+    FOREIGN KEY (lastList) REFERENCES lists (id) ON DELETE SET NULL
+);
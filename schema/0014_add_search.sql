@@ -0,0 +1,33 @@
+-- full-text indexes of items and lists, maintained by triggers; requires sqlite to be built with FTS5 (build tag
+-- sqlite_fts5). The ids are stored unindexed, as items and lists do not have stable integer ids.
+CREATE VIRTUAL TABLE items_search USING fts5(id UNINDEXED, text, tokenize = 'unicode61 remove_diacritics 2');
+
+INSERT INTO items_search (id, text) SELECT id, text FROM items;
+
+CREATE TRIGGER items_search_insert AFTER INSERT ON items BEGIN
+    INSERT INTO items_search (id, text) VALUES (new.id, new.text);
+END;
+
+CREATE TRIGGER items_search_update AFTER UPDATE OF text ON items BEGIN
+    UPDATE items_search SET text = new.text WHERE id = old.id;
+END;
+
+CREATE TRIGGER items_search_delete AFTER DELETE ON items BEGIN
+    DELETE FROM items_search WHERE id = old.id;
+END;
+
+CREATE VIRTUAL TABLE lists_search USING fts5(id UNINDEXED, title, notes, tokenize = 'unicode61 remove_diacritics 2');
+
+INSERT INTO lists_search (id, title, notes) SELECT id, title, notes FROM lists;
+
+CREATE TRIGGER lists_search_insert AFTER INSERT ON lists BEGIN
+    INSERT INTO lists_search (id, title, notes) VALUES (new.id, new.title, new.notes);
+END;
+
+CREATE TRIGGER lists_search_update AFTER UPDATE OF title, notes ON lists BEGIN
+    UPDATE lists_search SET title = new.title, notes = new.notes WHERE id = old.id;
+END;
+
+CREATE TRIGGER lists_search_delete AFTER DELETE ON lists BEGIN
+    DELETE FROM lists_search WHERE id = old.id;
+END;
@@ -0,0 +1,21 @@
+-- every change of an item, with the values of the item before and after the change as JSON. Items are not referenced,
+-- so the history of deleted items is kept; it is only removed together with the list.
+CREATE TABLE item_history (
+    seq         integer PRIMARY KEY AUTOINCREMENT,
+    item        text                NOT NULL,
+    list        text                NOT NULL,
+    -- create, update, check, move or delete
+    operation   text                NOT NULL,
+    -- the user who made the change, or null if it was made by the server, e.g. by the command line
+    user        integer,
+    date        text                NOT NULL,
+    oldValue    text,
+    newValue    text,
+    FOREIGN KEY (list) REFERENCES lists (id) ON DELETE CASCADE
+);
+
+CREATE INDEX item_history_list_index
+    ON item_history(list);
+
+CREATE INDEX item_history_item_index
+    ON item_history(item);
@@ -0,0 +1,24 @@
+-- client ids and temporary item ids are only unique per device, so operations are stored per device. Operations synced
+-- before devices were known keep an empty device id.
+CREATE TABLE sync_operations_by_device (
+    deviceId        text                NOT NULL,
+    clientId        text                NOT NULL,
+    type            text                NOT NULL,
+    status          text                NOT NULL,
+    clientItemId    text,
+    itemId          text,
+    error           text,
+    clientTimestamp text                NOT NULL,
+    date            text                NOT NULL,
+    PRIMARY KEY (deviceId, clientId)
+);
+
+INSERT INTO sync_operations_by_device (deviceId, clientId, type, status, clientItemId, itemId, error, clientTimestamp, date)
+    SELECT "", clientId, type, status, clientItemId, itemId, error, clientTimestamp, date FROM sync_operations;
+
+DROP TABLE sync_operations;
+
+ALTER TABLE sync_operations_by_device RENAME TO sync_operations;
+
+CREATE INDEX sync_operations_client_item_id_index
+    ON sync_operations(deviceId, clientItemId);
@@ -0,0 +1,3 @@
+-- suggestions group and look up items by their text ignoring case and surrounding whitespace
+CREATE INDEX items_normalized_text_index
+    ON items(lower(trim(text)));
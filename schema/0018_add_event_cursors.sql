@@ -0,0 +1,6 @@
+-- the sequence number of the last event each in-process consumer of the event log has processed, e.g. the webhook
+-- worker, so that it can resume where it stopped after a restart
+CREATE TABLE event_cursors (
+    name    text    PRIMARY KEY NOT NULL,
+    seq     integer             NOT NULL
+);
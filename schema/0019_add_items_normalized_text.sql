@@ -0,0 +1,8 @@
+-- the text of items ignoring case and whitespace, used by suggestions. It is set by the application, as lower only
+-- lowercases ASCII letters; items without one, e.g. the existing ones, are filled in on startup
+ALTER TABLE items ADD COLUMN normalizedText text;
+
+DROP INDEX items_normalized_text_index;
+
+CREATE INDEX items_normalized_text_index
+    ON items(normalizedText);
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/craftamap/shopping-list/db"
+)
+
+// ErrUnknownCategory is returned if a category is referenced that does not exist.
+var ErrUnknownCategory = errors.New("unknown category")
+
+// ErrDuplicateCategory is returned if a category with the same name already exists.
+var ErrDuplicateCategory = errors.New("category already exists")
+
+// ErrInvalidStoreLayout is returned if a store layout to be saved is not valid.
+var ErrInvalidStoreLayout = errors.New("invalid store layout")
+
+// assignCategory returns the category of an item with the given text. If category is set, it is validated and
+// remembered for items with the same text; an empty category means no category. Otherwise, the category last assigned
+// to an item with the same text is returned, if any.
+func assignCategory(ctx context.Context, s *txScope, text string, category *string) (*string, error) {
+	normalizedText := normalizeText(text)
+	if category == nil {
+		if normalizedText == "" {
+			return nil, nil
+		}
+		return s.categoryRepo.FindAssignment(ctx, normalizedText)
+	}
+	if *category == "" {
+		return nil, nil
+	}
+
+	_, err := s.categoryRepo.FindById(ctx, *category)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUnknownCategory
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category: %w", err)
+	}
+	if normalizedText != "" {
+		err = s.categoryRepo.SaveAssignment(ctx, normalizedText, *category)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return category, nil
+}
+
+// SortByStoreLayout sorts the items by the position of their category in the store layout. Items without a category,
+// or with a category that is not part of the layout, come last. Items of the same category keep their order.
+func SortByStoreLayout(items []db.ShoppingListItem, layout db.StoreLayout) {
+	positions := map[string]int{}
+	for i, category := range layout.Categories {
+		positions[category] = i
+	}
+	position := func(item db.ShoppingListItem) int {
+		if item.Category != nil {
+			p, ok := positions[*item.Category]
+			if ok {
+				return p
+			}
+		}
+		return len(layout.Categories)
+	}
+	slices.SortStableFunc(items, func(a, b db.ShoppingListItem) int {
+		return cmp.Compare(position(a), position(b))
+	})
+}
+
+type CategoryService struct {
+	dbConn       *sql.DB
+	categoryRepo *db.CategoryRepository
+	layoutRepo   *db.StoreLayoutRepository
+}
+
+func NewCategoryService(dbConn *sql.DB, categoryRepo *db.CategoryRepository, layoutRepo *db.StoreLayoutRepository) *CategoryService {
+	return &CategoryService{
+		dbConn:       dbConn,
+		categoryRepo: categoryRepo,
+		layoutRepo:   layoutRepo,
+	}
+}
+
+func (cs *CategoryService) GetAll(ctx context.Context) ([]db.Category, error) {
+	categories, err := cs.categoryRepo.FindAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting categories: %w", err)
+	}
+	return categories, nil
+}
+
+func (cs *CategoryService) Create(ctx context.Context, name string) (db.Category, error) {
+	name = strings.TrimSpace(name)
+	categories, err := cs.categoryRepo.FindAll(ctx)
+	if err != nil {
+		return db.Category{}, fmt.Errorf("Error getting categories: %w", err)
+	}
+	if slices.ContainsFunc(categories, func(c db.Category) bool { return strings.EqualFold(c.Name, name) }) {
+		return db.Category{}, ErrDuplicateCategory
+	}
+	category, err := cs.categoryRepo.Create(ctx, name)
+	if err != nil {
+		return db.Category{}, fmt.Errorf("Error creating category: %w", err)
+	}
+	return category, nil
+}
+
+// Delete deletes the category. Items of the category are left without category, and the category is removed from
+// all store layouts.
+func (cs *CategoryService) Delete(ctx context.Context, categoryId string) error {
+	return cs.categoryRepo.Delete(ctx, categoryId)
+}
+
+func (cs *CategoryService) GetAllLayouts(ctx context.Context) ([]db.StoreLayout, error) {
+	layouts, err := cs.layoutRepo.FindAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting store layouts: %w", err)
+	}
+	return layouts, nil
+}
+
+func (cs *CategoryService) FindLayoutById(ctx context.Context, layoutId string) (db.StoreLayout, error) {
+	layout, err := cs.layoutRepo.FindById(ctx, layoutId)
+	if err != nil {
+		return db.StoreLayout{}, fmt.Errorf("Error getting store layout: %w", err)
+	}
+	return layout, nil
+}
+
+// validateLayout checks that the layout has an unique name, and that its categories exist and are not contained twice.
+func validateLayout(ctx context.Context, layoutRepo *db.StoreLayoutRepository, categoryRepo *db.CategoryRepository, layoutId string, name string, categories []string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("%w: name must not be empty", ErrInvalidStoreLayout)
+	}
+	layouts, err := layoutRepo.FindAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get store layouts: %w", err)
+	}
+	if slices.ContainsFunc(layouts, func(l db.StoreLayout) bool { return l.ID != layoutId && l.Name == strings.TrimSpace(name) }) {
+		return fmt.Errorf("%w: a store layout with this name already exists", ErrInvalidStoreLayout)
+	}
+	for i, category := range categories {
+		if slices.Contains(categories[:i], category) {
+			return fmt.Errorf("%w: category %s is contained twice", ErrInvalidStoreLayout, category)
+		}
+		_, err := categoryRepo.FindById(ctx, category)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: unknown category %s", ErrInvalidStoreLayout, category)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get category: %w", err)
+		}
+	}
+	return nil
+}
+
+// CreateLayout creates a store layout with the given categories, in the order they are found in the store.
+func (cs *CategoryService) CreateLayout(ctx context.Context, name string, categories []string) (db.StoreLayout, error) {
+	var layout db.StoreLayout
+	err := db.RunInTx(ctx, cs.dbConn, func(tx *sql.Tx) error {
+		layoutRepo := cs.layoutRepo.WithTx(tx)
+		err := validateLayout(ctx, layoutRepo, cs.categoryRepo.WithTx(tx), "", name, categories)
+		if err != nil {
+			return err
+		}
+		id, err := layoutRepo.Create(ctx, strings.TrimSpace(name), categories)
+		if err != nil {
+			return err
+		}
+		layout, err = layoutRepo.FindById(ctx, id)
+		return err
+	})
+	return layout, err
+}
+
+// UpdateLayout replaces the name and the categories of the store layout.
+func (cs *CategoryService) UpdateLayout(ctx context.Context, layoutId string, name string, categories []string) (db.StoreLayout, error) {
+	var layout db.StoreLayout
+	err := db.RunInTx(ctx, cs.dbConn, func(tx *sql.Tx) error {
+		layoutRepo := cs.layoutRepo.WithTx(tx)
+		_, err := layoutRepo.FindById(ctx, layoutId)
+		if err != nil {
+			return fmt.Errorf("Failed to get store layout to be updated: %w", err)
+		}
+		err = validateLayout(ctx, layoutRepo, cs.categoryRepo.WithTx(tx), layoutId, name, categories)
+		if err != nil {
+			return err
+		}
+		err = layoutRepo.Update(ctx, layoutId, strings.TrimSpace(name), categories)
+		if err != nil {
+			return err
+		}
+		layout, err = layoutRepo.FindById(ctx, layoutId)
+		return err
+	})
+	return layout, err
+}
+
+func (cs *CategoryService) DeleteLayout(ctx context.Context, layoutId string) error {
+	return cs.layoutRepo.Delete(ctx, layoutId)
+}
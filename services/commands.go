@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/craftamap/shopping-list/events"
+)
+
+// CommandDispatcher executes commands sent by clients over the websocket connection, using the same services as the
+// HTTP API.
+type CommandDispatcher struct {
+	listService *ListService
+	itemService *ItemService
+}
+
+func NewCommandDispatcher(listService *ListService, itemService *ItemService) *CommandDispatcher {
+	return &CommandDispatcher{
+		listService: listService,
+		itemService: itemService,
+	}
+}
+
+func (cd *CommandDispatcher) HandleCommand(ctx context.Context, command events.Command) (any, error) {
+	switch command.Type {
+	case "CREATE_ITEM":
+		payload := struct {
+			ListID   string   `json:"listId"`
+			Text     string   `json:"text"`
+			Quantity *float64 `json:"quantity"`
+			Unit     *string  `json:"unit"`
+			Category *string  `json:"category"`
+			After    *string  `json:"after"`
+		}{}
+		err := json.Unmarshal(command.Payload, &payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		return cd.itemService.Create(ctx, payload.ListID, NewItem{
+			Text:     payload.Text,
+			Quantity: payload.Quantity,
+			Unit:     payload.Unit,
+			Category: payload.Category,
+			After:    payload.After,
+		})
+	case "UPDATE_ITEM":
+		payload := struct {
+			ItemID   string   `json:"itemId"`
+			Text     *string  `json:"text"`
+			Checked  *bool    `json:"checked"`
+			Quantity *float64 `json:"quantity"`
+			Unit     *string  `json:"unit"`
+			Category *string  `json:"category"`
+			Version  *int     `json:"version"`
+		}{}
+		err := json.Unmarshal(command.Payload, &payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		return cd.itemService.UpdateById(ctx, payload.ItemID, ItemChanges{
+			Text:      payload.Text,
+			Checked:   payload.Checked,
+			Quantity:  payload.Quantity,
+			Unit:      payload.Unit,
+			Category:  payload.Category,
+			IfVersion: payload.Version,
+		})
+	case "MOVE_ITEM":
+		payload := struct {
+			ItemID   string  `json:"itemId"`
+			AfterID  *string `json:"afterId"`
+			ParentID *string `json:"parentId"`
+			Version  *int    `json:"version"`
+		}{}
+		err := json.Unmarshal(command.Payload, &payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		return nil, cd.itemService.MoveById(ctx, payload.ItemID, MoveInstructions{
+			AfterId:   payload.AfterID,
+			ParentId:  payload.ParentID,
+			IfVersion: payload.Version,
+		})
+	case "DELETE_ITEM":
+		payload := struct {
+			ItemID  string `json:"itemId"`
+			Version *int   `json:"version"`
+		}{}
+		err := json.Unmarshal(command.Payload, &payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		return nil, cd.itemService.DeleteById(ctx, payload.ItemID, payload.Version)
+	case "UPDATE_LIST_STATUS":
+		payload := struct {
+			ListID  string `json:"listId"`
+			Status  string `json:"status"`
+			Version *int   `json:"version"`
+		}{}
+		err := json.Unmarshal(command.Payload, &payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		return cd.listService.Update(ctx, payload.ListID, ListDetails{
+			Status:    &payload.Status,
+			IfVersion: payload.Version,
+		})
+	case "UPDATE_LIST":
+		payload := struct {
+			ListID      string  `json:"listId"`
+			Status      *string `json:"status"`
+			Title       *string `json:"title"`
+			Notes       *string `json:"notes"`
+			PlannedDate *string `json:"plannedDate"`
+			Store       *string `json:"store"`
+			Version     *int    `json:"version"`
+		}{}
+		err := json.Unmarshal(command.Payload, &payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+		return cd.listService.Update(ctx, payload.ListID, ListDetails{
+			Status:      payload.Status,
+			Title:       payload.Title,
+			Notes:       payload.Notes,
+			PlannedDate: payload.PlannedDate,
+			Store:       payload.Store,
+			IfVersion:   payload.Version,
+		})
+	default:
+		return nil, fmt.Errorf("unknown command %s", command.Type)
+	}
+}
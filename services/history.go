@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/craftamap/shopping-list/auth"
+	"github.com/craftamap/shopping-list/db"
+)
+
+// Operations recorded in the item history.
+const (
+	HistoryOperationCreate = "create"
+	HistoryOperationUpdate = "update"
+	// HistoryOperationCheck is recorded if only the checked state of an item was changed
+	HistoryOperationCheck  = "check"
+	HistoryOperationMove   = "move"
+	HistoryOperationDelete = "delete"
+)
+
+// recordItemChange records a change of an item in the item history, together with the user who made it. oldItem is
+// nil for created items, and newItem is nil for deleted items.
+func (s *txScope) recordItemChange(ctx context.Context, operation string, oldItem *db.ShoppingListItem, newItem *db.ShoppingListItem) error {
+	item := newItem
+	if item == nil {
+		item = oldItem
+	}
+	var userId *int
+	if id, ok := auth.UserIDFromContext(ctx); ok {
+		userId = &id
+	}
+	err := s.historyRepo.Create(ctx, item.ID, item.List, operation, userId, oldItem, newItem)
+	if err != nil {
+		return fmt.Errorf("failed to record change of item %s: %w", item.ID, err)
+	}
+	return nil
+}
+
+func equalValues[T comparable](a *T, b *T) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// updateOperation returns the operation to be recorded for an update of an item: check if only its checked state was
+// changed, update otherwise.
+func updateOperation(oldItem db.ShoppingListItem, newItem db.ShoppingListItem) string {
+	onlyChecked := oldItem.Checked != newItem.Checked &&
+		oldItem.Text == newItem.Text &&
+		equalValues(oldItem.Quantity, newItem.Quantity) &&
+		equalValues(oldItem.Unit, newItem.Unit) &&
+		equalValues(oldItem.Note, newItem.Note) &&
+		equalValues(oldItem.Category, newItem.Category)
+	if onlyChecked {
+		return HistoryOperationCheck
+	}
+	return HistoryOperationUpdate
+}
+
+// FindHistoryByListId returns at most limit changes of items of the list, newest first.
+func (is *ItemService) FindHistoryByListId(ctx context.Context, listId string, limit int) ([]db.ItemHistoryEntry, error) {
+	_, err := is.listRepo.FindById(ctx, listId)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting list while finding item history: %w", err)
+	}
+	entries, err := is.tx.historyRepo.FindAllByListId(ctx, listId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("Error finding item history: %w", err)
+	}
+	return entries, nil
+}
+
+// FindHistoryByItemId returns at most limit changes of the item, newest first. The history of deleted items is kept,
+// so it can be found for items that do not exist anymore.
+func (is *ItemService) FindHistoryByItemId(ctx context.Context, itemId string, limit int) ([]db.ItemHistoryEntry, error) {
+	entries, err := is.tx.historyRepo.FindAllByItemId(ctx, itemId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("Error finding item history: %w", err)
+	}
+	return entries, nil
+}
@@ -1,27 +1,43 @@
 package services
 
 import (
+	"cmp"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"iter"
 	"log/slog"
+	"math"
 	"slices"
 
 	"github.com/craftamap/shopping-list/db"
 	"github.com/craftamap/shopping-list/events"
 )
 
+// ErrVersionConflict is returned if an item or list was changed since the version the change was based on.
+var ErrVersionConflict = errors.New("version conflict")
+
+// ItemService runs every change of items in its own transaction; events are only published once the change was
+// committed.
 type ItemService struct {
 	listRepo *db.ListRepository
 	itemRepo *db.ItemRepository
-	eventHub *events.EventHub
+	tx       *txRunner
 }
 
-func NewItemRepository(listRepo *db.ListRepository, itemRepo *db.ItemRepository, eventHub *events.EventHub) *ItemService {
+func NewItemRepository(dbConn *sql.DB, listRepo *db.ListRepository, itemRepo *db.ItemRepository, categoryRepo *db.CategoryRepository, historyRepo *db.ItemHistoryRepository, eventBus events.EventBus) *ItemService {
 	return &ItemService{
 		listRepo: listRepo,
 		itemRepo: itemRepo,
-		eventHub: eventHub,
+		tx: &txRunner{
+			dbConn:       dbConn,
+			listRepo:     listRepo,
+			itemRepo:     itemRepo,
+			categoryRepo: categoryRepo,
+			historyRepo:  historyRepo,
+			eventBus:     eventBus,
+		},
 	}
 }
 
@@ -37,6 +53,14 @@ func (is *ItemService) FindAllByListId(ctx context.Context, listId string) ([]db
 	return items, nil
 }
 
+func (is *ItemService) FindById(ctx context.Context, itemId string) (db.ShoppingListItem, error) {
+	item, err := is.itemRepo.FindByID(ctx, itemId)
+	if err != nil {
+		return db.ShoppingListItem{}, fmt.Errorf("Error finding item: %w", err)
+	}
+	return item, nil
+}
+
 func findHighestSort(existingItems []db.ShoppingListItem) [2]int {
 	sortFractions := [2]int{0, 1}
 	sort := 0.0
@@ -49,79 +73,193 @@ func findHighestSort(existingItems []db.ShoppingListItem) [2]int {
 	return sortFractions
 }
 
-func (is *ItemService) Create(ctx context.Context, listId string, text string, after *string) error {
-	_, err := is.listRepo.FindById(ctx, listId)
+// NewItem contains the fields of an item to be created.
+type NewItem struct {
+	Text string
+	// Quantity and Unit, if Quantity is not set, are parsed from Text, e.g. "500 g butter"
+	Quantity *float64
+	Unit     *string
+	// Category, if not set, is assigned based on the category previously assigned to items with the same text. An
+	// empty Category means no category.
+	Category *string
+	// After, if set, is the id of the item the new item is placed after; otherwise, it is placed at the end of the list
+	After *string
+}
+
+// ItemChanges contains the fields of an item to be changed. Fields that are nil are left unchanged.
+type ItemChanges struct {
+	Text    *string
+	Checked *bool
+	// Quantity and Unit, if Quantity is not set but Text is, are parsed from Text
+	Quantity *float64
+	Unit     *string
+	// Category, if set, is the id of the new category; an empty Category removes the category
+	Category *string
+	// IfVersion, if set, is the version the item is expected to have
+	IfVersion *int
+}
+
+// Create creates a new item at the end of the list, or directly after the item with the id newItem.After.
+func (is *ItemService) Create(ctx context.Context, listId string, newItem NewItem) (db.ShoppingListItem, error) {
+	var item db.ShoppingListItem
+	err := is.tx.run(ctx, func(s *txScope) error {
+		var err error
+		item, err = is.create(ctx, s, listId, newItem)
+		return err
+	})
+	return item, err
+}
+
+func (is *ItemService) create(ctx context.Context, s *txScope, listId string, newItem NewItem) (db.ShoppingListItem, error) {
+	text, quantity, unit := newItem.Text, newItem.Quantity, normalizeUnitInput(newItem.Unit)
+	if quantity == nil {
+		text, quantity, unit = ParseQuantity(text)
+	}
+
+	_, err := s.listRepo.FindById(ctx, listId)
+	if err != nil {
+		return db.ShoppingListItem{}, fmt.Errorf("Error getting list while creating item: %w", err)
+	}
+	category, err := assignCategory(ctx, s, text, newItem.Category)
 	if err != nil {
-		return fmt.Errorf("Error getting list while creating item: %w", err)
+		return db.ShoppingListItem{}, err
 	}
 
-	existingItems, err := is.itemRepo.FindAllByListId(ctx, listId)
+	existingItems, err := s.itemRepo.FindAllByListId(ctx, listId)
 	if err != nil {
-		return fmt.Errorf("Error getting list while finding items: %w", err)
+		return db.ShoppingListItem{}, fmt.Errorf("Error getting list while finding items: %w", err)
 	}
 	highestSort := findHighestSort(existingItems)
 	newSort := [2]int{highestSort[0] + 1, highestSort[1]}
+	if !sortFractionsUsable(newSort, float64(highestSort[0])/float64(highestSort[1]), math.Inf(1)) {
+		slog.Info("sort fractions exhausted, renormalizing siblings", "list", listId)
+		_, err = is.renormalizeSiblings(ctx, s, existingItems, nil)
+		if err != nil {
+			return db.ShoppingListItem{}, err
+		}
+		existingItems, err = s.itemRepo.FindAllByListId(ctx, listId)
+		if err != nil {
+			return db.ShoppingListItem{}, fmt.Errorf("Error getting list while finding items: %w", err)
+		}
+		highestSort = findHighestSort(existingItems)
+		newSort = [2]int{highestSort[0] + 1, highestSort[1]}
+	}
 
-	itemId, err := is.itemRepo.Create(ctx, listId, text, newSort)
+	itemId, err := s.itemRepo.Create(ctx, listId, text, quantity, unit, category, newSort)
 	if err != nil {
-		return fmt.Errorf("Error getting list while creating item: %w", err)
+		return db.ShoppingListItem{}, fmt.Errorf("Error getting list while creating item: %w", err)
 	}
 
-	go func() {
-		err = is.eventHub.Publish(events.NewItemsInListChangedEvent(listId))
-		if err != nil {
-			slog.Error("error during publish", "err", err)
-		}
-	}()
+	item, err := s.itemRepo.FindByID(ctx, itemId)
+	if err != nil {
+		return db.ShoppingListItem{}, fmt.Errorf("Error getting item after creating it: %w", err)
+	}
+	err = s.recordItemChange(ctx, HistoryOperationCreate, nil, &item)
+	if err != nil {
+		return db.ShoppingListItem{}, err
+	}
+	s.publishItemEvent(events.NewItemCreatedEvent(item))
 
-	if after == nil {
-		return nil
+	if newItem.After == nil {
+		return item, nil
 	}
 
-	return is.MoveById(ctx, itemId, MoveInstructions{
-		AfterId: after,
+	err = is.moveById(ctx, s, itemId, MoveInstructions{
+		AfterId: newItem.After,
 	})
+	if err != nil {
+		return db.ShoppingListItem{}, err
+	}
+	return s.itemRepo.FindByID(ctx, itemId)
 }
 
-func (is *ItemService) UpdateById(ctx context.Context, itemId string, text *string, checked *bool) error {
-	if text == nil && checked == nil {
-		return nil
-	}
-	item, err := is.itemRepo.FindByID(ctx, itemId)
+// UpdateById changes the given fields of the item. If changes.IfVersion is set, the item is only updated if it still
+// has this version; otherwise, ErrVersionConflict is returned.
+func (is *ItemService) UpdateById(ctx context.Context, itemId string, changes ItemChanges) (db.ShoppingListItem, error) {
+	var item db.ShoppingListItem
+	err := is.tx.run(ctx, func(s *txScope) error {
+		var err error
+		item, err = is.updateById(ctx, s, itemId, changes)
+		return err
+	})
+	return item, err
+}
+
+func (is *ItemService) updateById(ctx context.Context, s *txScope, itemId string, changes ItemChanges) (db.ShoppingListItem, error) {
+	item, err := s.itemRepo.FindByID(ctx, itemId)
 	if err != nil {
-		return fmt.Errorf("Failed to get item to be updated: %w", err)
+		return db.ShoppingListItem{}, fmt.Errorf("Failed to get item to be updated: %w", err)
+	}
+	if changes.IfVersion != nil && item.Version != *changes.IfVersion {
+		return db.ShoppingListItem{}, ErrVersionConflict
+	}
+	if changes.Text == nil && changes.Checked == nil && changes.Quantity == nil && changes.Unit == nil && changes.Category == nil {
+		return item, nil
 	}
 
-	if checked != nil {
-		err := is.itemRepo.UpdateChecked(ctx, itemId, *checked)
-		if err != nil {
-			return fmt.Errorf("Failed to update checked for item: %w", err)
+	newText := item.Text
+	newQuantity := item.Quantity
+	newUnit := item.Unit
+	if changes.Text != nil {
+		newText = *changes.Text
+		// the quantity is taken from the text, so that removing it from the text removes it from the item
+		if changes.Quantity == nil {
+			newText, newQuantity, newUnit = parseQuantity(newText, item.Unit)
 		}
 	}
-	if text != nil {
-		err := is.itemRepo.UpdateText(ctx, itemId, *text)
+	if changes.Quantity != nil {
+		newQuantity = changes.Quantity
+	}
+	if changes.Unit != nil {
+		newUnit = normalizeUnitInput(changes.Unit)
+	}
+	newChecked := item.Checked
+	if changes.Checked != nil {
+		newChecked = *changes.Checked
+	}
+	newCategory := item.Category
+	if changes.Category != nil || (changes.Text != nil && item.Category == nil) {
+		newCategory, err = assignCategory(ctx, s, newText, changes.Category)
 		if err != nil {
-			return fmt.Errorf("Failed to update text for item")
+			return db.ShoppingListItem{}, err
 		}
 	}
+	err = s.itemRepo.Update(ctx, itemId, newText, newChecked, newQuantity, newUnit, item.Note, newCategory)
+	if err != nil {
+		return db.ShoppingListItem{}, fmt.Errorf("Failed to update item: %w", err)
+	}
 
-	go func() {
-		err = is.eventHub.Publish(events.NewItemsInListChangedEvent(item.List))
-		if err != nil {
-			slog.Error("error during publish", "err", err)
-			return
-		}
-	}()
-	return nil
+	updatedItem, err := s.itemRepo.FindByID(ctx, itemId)
+	if err != nil {
+		return db.ShoppingListItem{}, fmt.Errorf("Failed to get item after updating: %w", err)
+	}
+	err = s.recordItemChange(ctx, updateOperation(item, updatedItem), &item, &updatedItem)
+	if err != nil {
+		return db.ShoppingListItem{}, err
+	}
+	s.publishItemEvent(events.NewItemUpdatedEvent(updatedItem))
+	return updatedItem, nil
 }
 
-func (is *ItemService) DeleteById(ctx context.Context, itemId string) error {
-	item, err := is.itemRepo.FindByID(ctx, itemId)
+// DeleteById deletes the item. Its children are moved to the position of the item. If ifVersion is set, the item is
+// only deleted if it still has this version; otherwise, ErrVersionConflict is returned.
+// Moving the children and deleting the item happens atomically.
+func (is *ItemService) DeleteById(ctx context.Context, itemId string, ifVersion *int) error {
+	return is.tx.run(ctx, func(s *txScope) error {
+		return is.deleteById(ctx, s, itemId, ifVersion)
+	})
+}
+
+func (is *ItemService) deleteById(ctx context.Context, s *txScope, itemId string, ifVersion *int) error {
+	item, err := s.itemRepo.FindByID(ctx, itemId)
 	if err != nil {
 		return fmt.Errorf("Failed to find item to delete %w", err)
 	}
+	if ifVersion != nil && item.Version != *ifVersion {
+		return ErrVersionConflict
+	}
 
-	allItems, err := is.FindAllByListId(ctx, item.List)
+	allItems, err := s.itemRepo.FindAllByListId(ctx, item.List)
 	if err != nil {
 		return fmt.Errorf("Failed to find items %w", err)
 	}
@@ -145,7 +283,7 @@ func (is *ItemService) DeleteById(ctx context.Context, itemId string) error {
 	})
 	slog.Info("children ", "children", children)
 	for _, child := range children {
-		err := is.MoveById(ctx, child.ID, MoveInstructions{
+		err := is.moveById(ctx, s, child.ID, MoveInstructions{
 			AfterId: &item.ID,
 		})
 		if err != nil {
@@ -153,19 +291,23 @@ func (is *ItemService) DeleteById(ctx context.Context, itemId string) error {
 		}
 	}
 
-	err = is.itemRepo.Delete(ctx, itemId)
+	err = s.itemRepo.Delete(ctx, itemId)
 	if err != nil {
 		return fmt.Errorf("Failed to delete item %w", err)
 	}
-	go func() {
-		_ = is.eventHub.Publish(events.NewItemsInListChangedEvent(item.List))
-	}()
+	err = s.recordItemChange(ctx, HistoryOperationDelete, &item, nil)
+	if err != nil {
+		return err
+	}
+	s.publishItemEvent(events.NewItemDeletedEvent(item))
 	return nil
 }
 
 type MoveInstructions struct {
 	AfterId  *string
 	ParentId *string
+	// IfVersion, if set, is the version the item is expected to have
+	IfVersion *int
 }
 
 func findByID(items []db.ShoppingListItem, id string) (*db.ShoppingListItem, bool) {
@@ -199,24 +341,12 @@ func getAnchestors(items []db.ShoppingListItem, startItemID string) iter.Seq[*db
 
 }
 
-func (is *ItemService) MoveById(ctx context.Context, itemId string, moveInstr MoveInstructions) error {
-	item, err := is.itemRepo.FindByID(ctx, itemId)
-	if err != nil {
-		return fmt.Errorf("failed to get item while moving: %w", err)
-	}
-
-	items, err := is.itemRepo.FindAllByListId(ctx, item.List)
-	if err != nil {
-		return fmt.Errorf("failed to get items while moving: %w", err)
-	}
-
-	var after *db.ShoppingListItem  // moved item will be placed AFTER after
-	var before *db.ShoppingListItem // moved item will be placed BEFORE before
-	var parentId *string
+// findNeighbours finds the items the moved item will be placed between, and its new parent.
+func findNeighbours(items []db.ShoppingListItem, itemId string, moveInstr MoveInstructions) (after *db.ShoppingListItem, before *db.ShoppingListItem, parentId *string, err error) {
 	if moveInstr.AfterId != nil {
 		foundById, ok := findByID(items, *moveInstr.AfterId)
 		if !ok {
-			return fmt.Errorf("failed to find item with AfterId")
+			return nil, nil, nil, fmt.Errorf("failed to find item with AfterId")
 		}
 		after = foundById
 		parentId = after.Parent
@@ -238,7 +368,7 @@ func (is *ItemService) MoveById(ctx context.Context, itemId string, moveInstr Mo
 	} else if moveInstr.ParentId != nil {
 		parent, ok := findByID(items, *moveInstr.ParentId)
 		if !ok {
-			return fmt.Errorf("failed to find item with ParentId")
+			return nil, nil, nil, fmt.Errorf("failed to find item with ParentId")
 		}
 		parentId = &parent.ID
 
@@ -252,15 +382,12 @@ func (is *ItemService) MoveById(ctx context.Context, itemId string, moveInstr Mo
 
 		before = firstItemWithParent
 	}
-	if parentId != nil {
-		for anchestor := range getAnchestors(items, *parentId) {
-			if anchestor.ID == item.ID {
-				// this means that the new parent would have the item as an anchestor, leading to a looping tree
-				return fmt.Errorf("illegal tree")
-			}
-		}
-	}
+	return after, before, parentId, nil
+}
 
+// mediantSortFractions returns the mediant of the sort fractions of after and before, which lies strictly between
+// them. A missing after is treated as 0/1, a missing before as 1/0.
+func mediantSortFractions(after *db.ShoppingListItem, before *db.ShoppingListItem) [2]int {
 	numerator := 0
 	if after != nil {
 		numerator = numerator + after.SortFractions[0]
@@ -285,16 +412,241 @@ func (is *ItemService) MoveById(ctx context.Context, itemId string, moveInstr Mo
 		denominator = denominator + 0
 	}
 
-	newSortFractions := []int{numerator, denominator}
+	return [2]int{numerator, denominator}
+}
 
-	err = is.itemRepo.Move(ctx, itemId, parentId, newSortFractions)
+// maxSortFraction is the largest numerator or denominator that fits into the uint32s sort fractions are stored as.
+const maxSortFraction = math.MaxUint32
+
+// sortFractionsUsable reports whether sortFractions can be stored, and whether its float64 value, which is used for
+// ordering, still lies strictly between lower and upper. Repeatedly placing items between the same two neighbours
+// grows numerator and denominator until one of both is no longer the case.
+func sortFractionsUsable(sortFractions [2]int, lower float64, upper float64) bool {
+	if sortFractions[0] > maxSortFraction || sortFractions[1] > maxSortFraction {
+		return false
+	}
+	sort := float64(sortFractions[0]) / float64(sortFractions[1])
+	return sort > lower && sort < upper
+}
+
+// sortBounds returns the sort values the moved item has to be placed between.
+func sortBounds(after *db.ShoppingListItem, before *db.ShoppingListItem) (float64, float64) {
+	lower := 0.0
+	if after != nil {
+		lower = after.Sort
+	}
+	upper := math.Inf(1)
+	if before != nil {
+		upper = before.Sort
+	}
+	return lower, upper
+}
+
+// compareSortFractions compares the exact values of the sort fractions of a and b, as their float64 sort values might
+// be equal already.
+func compareSortFractions(a db.ShoppingListItem, b db.ShoppingListItem) int {
+	// the fractions are stored as uint32s, so their products always fit into an uint64
+	return cmp.Compare(uint64(a.SortFractions[0])*uint64(b.SortFractions[1]), uint64(b.SortFractions[0])*uint64(a.SortFractions[1]))
+}
+
+func (is *ItemService) MoveById(ctx context.Context, itemId string, moveInstr MoveInstructions) error {
+	return is.tx.run(ctx, func(s *txScope) error {
+		return is.moveById(ctx, s, itemId, moveInstr)
+	})
+}
+
+func (is *ItemService) moveById(ctx context.Context, s *txScope, itemId string, moveInstr MoveInstructions) error {
+	item, err := s.itemRepo.FindByID(ctx, itemId)
+	if err != nil {
+		return fmt.Errorf("failed to get item while moving: %w", err)
+	}
+	if moveInstr.IfVersion != nil && item.Version != *moveInstr.IfVersion {
+		return ErrVersionConflict
+	}
+
+	items, err := s.itemRepo.FindAllByListId(ctx, item.List)
+	if err != nil {
+		return fmt.Errorf("failed to get items while moving: %w", err)
+	}
+
+	after, before, parentId, err := findNeighbours(items, itemId, moveInstr)
+	if err != nil {
+		return err
+	}
+	if parentId != nil {
+		for anchestor := range getAnchestors(items, *parentId) {
+			if anchestor.ID == item.ID {
+				// this means that the new parent would have the item as an anchestor, leading to a looping tree
+				return fmt.Errorf("illegal tree")
+			}
+		}
+	}
+
+	newSortFractions := mediantSortFractions(after, before)
+	lower, upper := sortBounds(after, before)
+	if !sortFractionsUsable(newSortFractions, lower, upper) {
+		slog.Info("sort fractions exhausted, renormalizing siblings", "list", item.List, "parent", parentId)
+		_, err = is.renormalizeSiblings(ctx, s, items, parentId)
+		if err != nil {
+			return err
+		}
+		items, err = s.itemRepo.FindAllByListId(ctx, item.List)
+		if err != nil {
+			return fmt.Errorf("failed to get items after renormalizing: %w", err)
+		}
+		after, before, _, err = findNeighbours(items, itemId, moveInstr)
+		if err != nil {
+			return err
+		}
+		newSortFractions = mediantSortFractions(after, before)
+	}
+
+	err = s.itemRepo.Move(ctx, itemId, parentId, newSortFractions[:])
 	if err != nil {
 		return fmt.Errorf("failed to move item: %w", err)
 	}
-	go func() {
-		// FIXME: check error response
-		_ = is.eventHub.Publish(events.NewItemsInListChangedEvent(item.List))
-	}()
 
+	movedItem, err := s.itemRepo.FindByID(ctx, itemId)
+	if err != nil {
+		return fmt.Errorf("failed to get item after moving: %w", err)
+	}
+	err = s.recordItemChange(ctx, HistoryOperationMove, &item, &movedItem)
+	if err != nil {
+		return err
+	}
+	s.publishItemEvent(events.NewItemMovedEvent(movedItem))
+
+	return nil
+}
+
+// RenormalizeList resets the sort fractions of all items of the list to 1/1, 2/1, 3/1, ... per parent, keeping their
+// order. It returns the number of items that were changed.
+func (is *ItemService) RenormalizeList(ctx context.Context, listId string) (int, error) {
+	changed := 0
+	err := is.tx.run(ctx, func(s *txScope) error {
+		_, err := s.listRepo.FindById(ctx, listId)
+		if err != nil {
+			return fmt.Errorf("failed to get list while renormalizing: %w", err)
+		}
+		items, err := s.itemRepo.FindAllByListId(ctx, listId)
+		if err != nil {
+			return fmt.Errorf("failed to get items while renormalizing: %w", err)
+		}
+
+		parents := map[string]*string{}
+		for _, item := range items {
+			key := ""
+			if item.Parent != nil {
+				key = *item.Parent
+			}
+			parents[key] = item.Parent
+		}
+		for _, parentId := range parents {
+			n, err := is.renormalizeSiblings(ctx, s, items, parentId)
+			if err != nil {
+				return err
+			}
+			changed += n
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// renormalizeSiblings resets the sort fractions of all items with the given parent to 1/1, 2/1, 3/1, ..., keeping
+// their order. It returns the number of items that were changed.
+func (is *ItemService) renormalizeSiblings(ctx context.Context, s *txScope, items []db.ShoppingListItem, parentId *string) (int, error) {
+	siblings := slices.DeleteFunc(slices.Clone(items), func(i db.ShoppingListItem) bool {
+		if i.Parent == nil || parentId == nil {
+			return i.Parent != parentId
+		}
+		return *i.Parent != *parentId
+	})
+	// Sort is what the order of the list is based on everywhere else; the fractions only break ties between items
+	// whose Sort was rounded to the same value
+	slices.SortStableFunc(siblings, func(a, b db.ShoppingListItem) int {
+		return cmp.Or(cmp.Compare(a.Sort, b.Sort), compareSortFractions(a, b))
+	})
+
+	changed := 0
+	for k, sibling := range siblings {
+		sortFractions := []int{k + 1, 1}
+		if slices.Equal(sibling.SortFractions, sortFractions) {
+			continue
+		}
+		// the order does not change, so neither the version is incremented nor the history recorded
+		err := s.itemRepo.SetSortFractions(ctx, sibling.ID, sortFractions)
+		if err != nil {
+			return changed, fmt.Errorf("failed to renormalize item: %w", err)
+		}
+		movedItem, err := s.itemRepo.FindByID(ctx, sibling.ID)
+		if err != nil {
+			return changed, fmt.Errorf("failed to get item after renormalizing: %w", err)
+		}
+		s.publishItemEvent(events.NewItemMovedEvent(movedItem))
+		changed++
+	}
+	return changed, nil
+}
+
+// copyItems copies the items into the list, keeping their nesting and their order. If uncheckedOnly is set, checked
+// items are skipped, unless they contain unchecked items.
+func copyItems(ctx context.Context, s *txScope, items []db.ShoppingListItem, listId string, uncheckedOnly bool) error {
+	itemsById := map[string]db.ShoppingListItem{}
+	for _, item := range items {
+		itemsById[item.ID] = item
+	}
+
+	keep := map[string]bool{}
+	for _, item := range items {
+		if uncheckedOnly && item.Checked {
+			continue
+		}
+		// keep the parents as well, so the item stays in its group
+		for id := &item.ID; id != nil && !keep[*id]; id = itemsById[*id].Parent {
+			keep[*id] = true
+		}
+	}
+
+	// parents have to be copied before their children, but children can come first, as items are ordered by sort
+	copies := map[string]string{}
+	var copyItem func(item db.ShoppingListItem) (string, error)
+	copyItem = func(item db.ShoppingListItem) (string, error) {
+		if id, ok := copies[item.ID]; ok {
+			return id, nil
+		}
+		var parentId *string
+		if item.Parent != nil {
+			id, err := copyItem(itemsById[*item.Parent])
+			if err != nil {
+				return "", err
+			}
+			parentId = &id
+		}
+		id, err := s.itemRepo.Copy(ctx, item, listId, parentId)
+		if err != nil {
+			return "", fmt.Errorf("failed to copy item: %w", err)
+		}
+		copiedItem, err := s.itemRepo.FindByID(ctx, id)
+		if err != nil {
+			return "", fmt.Errorf("failed to get item after copying: %w", err)
+		}
+		err = s.recordItemChange(ctx, HistoryOperationCreate, nil, &copiedItem)
+		if err != nil {
+			return "", err
+		}
+		copies[item.ID] = id
+		return id, nil
+	}
+
+	for _, item := range items {
+		if !keep[item.ID] {
+			continue
+		}
+		_, err := copyItem(item)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
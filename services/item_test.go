@@ -0,0 +1,68 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/craftamap/shopping-list/db"
+)
+
+func TestMediantSortFractions(t *testing.T) {
+	tests := []struct {
+		name   string
+		after  *db.ShoppingListItem
+		before *db.ShoppingListItem
+		want   [2]int
+	}{
+		{name: "empty list", want: [2]int{1, 1}},
+		{name: "first item", before: &db.ShoppingListItem{SortFractions: []int{1, 1}}, want: [2]int{1, 2}},
+		{name: "last item", after: &db.ShoppingListItem{SortFractions: []int{3, 1}}, want: [2]int{4, 1}},
+		{
+			name:   "between items",
+			after:  &db.ShoppingListItem{SortFractions: []int{1, 2}},
+			before: &db.ShoppingListItem{SortFractions: []int{2, 3}},
+			want:   [2]int{3, 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mediantSortFractions(tt.after, tt.before)
+			if got != tt.want {
+				t.Errorf("mediantSortFractions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortFractionsUsable(t *testing.T) {
+	tests := []struct {
+		name          string
+		sortFractions [2]int
+		lower         float64
+		upper         float64
+		want          bool
+	}{
+		{name: "between bounds", sortFractions: [2]int{3, 2}, lower: 1, upper: 2, want: true},
+		{name: "on lower bound", sortFractions: [2]int{1, 1}, lower: 1, upper: 2, want: false},
+		{name: "on upper bound", sortFractions: [2]int{2, 1}, lower: 1, upper: 2, want: false},
+		{name: "numerator too large", sortFractions: [2]int{maxSortFraction + 1, maxSortFraction}, lower: 1, upper: 2, want: false},
+		{name: "denominator too large", sortFractions: [2]int{1, maxSortFraction + 1}, lower: 0, upper: 1, want: false},
+		{name: "largest storable", sortFractions: [2]int{1, maxSortFraction}, lower: 0, upper: 1, want: true},
+		{
+			// the mediant of 2147483617/2147483618 and 2147483618/2147483619 lies between them, but all three are
+			// the same float
+			name:          "not distinguishable as float",
+			sortFractions: [2]int{4294967235, 4294967237},
+			lower:         2147483617.0 / 2147483618.0,
+			upper:         2147483618.0 / 2147483619.0,
+			want:          false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sortFractionsUsable(tt.sortFractions, tt.lower, tt.upper)
+			if got != tt.want {
+				t.Errorf("sortFractionsUsable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,42 +2,187 @@ package services
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/craftamap/shopping-list/db"
 	"github.com/craftamap/shopping-list/events"
 )
 
+// ListStatuses contains all valid values for the status of a list.
+var ListStatuses = []string{"inprogress", "todo", "done"}
+
+// ListService runs every change of lists in its own transaction; events are only published once the change was
+// committed.
 type ListService struct {
-	listRepo *db.ListRepository
-	eventHub *events.EventHub
+	listRepo        *db.ListRepository
+	storeLayoutRepo *db.StoreLayoutRepository
+	templateRepo    *db.TemplateRepository
+	tx              *txRunner
 }
 
-func NewListService(listRepo *db.ListRepository, eventHub *events.EventHub) *ListService {
+func NewListService(dbConn *sql.DB, listRepo *db.ListRepository, itemRepo *db.ItemRepository, categoryRepo *db.CategoryRepository, storeLayoutRepo *db.StoreLayoutRepository, templateRepo *db.TemplateRepository, historyRepo *db.ItemHistoryRepository, eventBus events.EventBus) *ListService {
 	return &ListService{
-		listRepo: listRepo,
-		eventHub: eventHub,
+		listRepo:        listRepo,
+		storeLayoutRepo: storeLayoutRepo,
+		templateRepo:    templateRepo,
+		tx: &txRunner{
+			dbConn:       dbConn,
+			listRepo:     listRepo,
+			itemRepo:     itemRepo,
+			categoryRepo: categoryRepo,
+			historyRepo:  historyRepo,
+			eventBus:     eventBus,
+		},
+	}
+}
+
+// ErrInvalidList is returned if the details of a list to be saved are not valid.
+var ErrInvalidList = errors.New("invalid list")
+
+const maxListTitleLength = 100
+const maxListNotesLength = 2000
+
+// ListDetails contains the fields of a list that can be set by users. When updating a list, fields that are nil are
+// left unchanged, and empty strings remove the optional fields.
+type ListDetails struct {
+	Status *string
+	Title  *string
+	Notes  *string
+	// PlannedDate is the day the shopping trip is planned for, formatted as YYYY-MM-DD
+	PlannedDate *string
+	// Store is the id of a store layout
+	Store *string
+	// Archived archives or restores the list; archived lists are hidden by default
+	Archived *bool
+	// IfVersion, if set, is the version the list is expected to have when updating it
+	IfVersion *int
+}
+
+// emptyToNil returns nil for empty optional fields, so they are removed.
+func emptyToNil(value *string) *string {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return nil
+	}
+	return value
+}
+
+// apply validates the details, and applies them to list.
+func (ls *ListService) apply(ctx context.Context, list *db.ShoppingList, details ListDetails) error {
+	if details.Status != nil {
+		if !slices.Contains(ListStatuses, *details.Status) {
+			return fmt.Errorf("%w: invalid status", ErrInvalidList)
+		}
+		list.Status = *details.Status
+	}
+	if details.Title != nil {
+		title := strings.TrimSpace(*details.Title)
+		if utf8.RuneCountInString(title) > maxListTitleLength {
+			return fmt.Errorf("%w: title must not be longer than %d characters", ErrInvalidList, maxListTitleLength)
+		}
+		list.Title = title
 	}
+	if details.Notes != nil {
+		if utf8.RuneCountInString(*details.Notes) > maxListNotesLength {
+			return fmt.Errorf("%w: notes must not be longer than %d characters", ErrInvalidList, maxListNotesLength)
+		}
+		list.Notes = emptyToNil(details.Notes)
+	}
+	if details.PlannedDate != nil {
+		plannedDate := emptyToNil(details.PlannedDate)
+		if plannedDate != nil {
+			_, err := time.Parse(time.DateOnly, *plannedDate)
+			if err != nil {
+				return fmt.Errorf("%w: planned date must be formatted as YYYY-MM-DD", ErrInvalidList)
+			}
+		}
+		list.PlannedDate = plannedDate
+	}
+	if details.Store != nil {
+		store := emptyToNil(details.Store)
+		if store != nil {
+			_, err := ls.storeLayoutRepo.FindById(ctx, *store)
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%w: unknown store %s", ErrInvalidList, *store)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to get store: %w", err)
+			}
+		}
+		list.Store = store
+	}
+	if details.Archived != nil {
+		if !*details.Archived {
+			list.ArchivedAt = nil
+		} else if list.ArchivedAt == nil {
+			archivedAt := time.Now().Format(time.RFC3339)
+			list.ArchivedAt = &archivedAt
+		}
+	}
+	return nil
 }
 
-func (ls *ListService) GetAll(ctx context.Context) ([]db.ShoppingList, error) {
-	lists, err := ls.listRepo.FindAll(ctx)
+// GetAll returns all lists. If archived is set, only archived or only not archived lists are returned.
+func (ls *ListService) GetAll(ctx context.Context, archived *bool) ([]db.ShoppingList, error) {
+	lists, err := ls.listRepo.FindAll(ctx, archived)
 	if err != nil {
 		return nil, fmt.Errorf("Error getting lists: %w", err)
 	}
 	return lists, nil
 }
 
-func (ls *ListService) Create(ctx context.Context) (db.ShoppingList, error) {
-	list, err := ls.listRepo.Create(ctx)
+// Create creates a list with the given details. New lists have the status todo, unless details.Status is set.
+func (ls *ListService) Create(ctx context.Context, details ListDetails) (db.ShoppingList, error) {
+	return ls.CreateFromTemplates(ctx, details, nil)
+}
+
+// CreateFromTemplates creates a list with the given details, containing the items of the templates. The items of each
+// template follow the items of the previous template; a template that is given twice is only used once.
+func (ls *ListService) CreateFromTemplates(ctx context.Context, details ListDetails, templateIds []string) (db.ShoppingList, error) {
+	list := db.ShoppingList{Status: "todo"}
+	err := ls.apply(ctx, &list, details)
 	if err != nil {
-		return db.ShoppingList{}, fmt.Errorf("Error creating list: %w", err)
+		return db.ShoppingList{}, err
 	}
-	go func() {
-		// FIXME: check error response
-		_ = ls.eventHub.Publish(events.NewListCreatedEvent(list.ID))
-	}()
-	return list, nil
+	if list.ArchivedAt != nil {
+		return db.ShoppingList{}, fmt.Errorf("%w: new lists can not be archived", ErrInvalidList)
+	}
+
+	templates := []db.Template{}
+	for i, templateId := range templateIds {
+		if slices.Contains(templateIds[:i], templateId) {
+			continue
+		}
+		template, err := ls.templateRepo.FindById(ctx, templateId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.ShoppingList{}, fmt.Errorf("%w: unknown template %s", ErrInvalidList, templateId)
+		}
+		if err != nil {
+			return db.ShoppingList{}, fmt.Errorf("failed to get template: %w", err)
+		}
+		templates = append(templates, template)
+	}
+
+	err = ls.tx.run(ctx, func(s *txScope) error {
+		var err error
+		list, err = s.listRepo.Create(ctx, list.Status, list.Title, list.Notes, list.PlannedDate, list.Store)
+		if err != nil {
+			return fmt.Errorf("Error creating list: %w", err)
+		}
+		err = copyItems(ctx, s, listItemsOfTemplates(templates), list.ID, false)
+		if err != nil {
+			return err
+		}
+		s.publish(events.NewListCreatedEvent(list))
+		return nil
+	})
+	return list, err
 }
 
 func (ls *ListService) FindById(ctx context.Context, listId string) (db.ShoppingList, error) {
@@ -48,25 +193,151 @@ func (ls *ListService) FindById(ctx context.Context, listId string) (db.Shopping
 	return list, nil
 }
 
-func (ls *ListService) Update(ctx context.Context, listId string, status string) (db.ShoppingList, error) {
-	_, err := ls.FindById(ctx, listId)
+// Update changes the given details of the list. If details.IfVersion is set, the list is only updated if it still has
+// this version; otherwise, ErrVersionConflict is returned.
+func (ls *ListService) Update(ctx context.Context, listId string, details ListDetails) (db.ShoppingList, error) {
+	var list db.ShoppingList
+	err := ls.tx.run(ctx, func(s *txScope) error {
+		var err error
+		list, err = ls.update(ctx, s, listId, details)
+		return err
+	})
+	return list, err
+}
+
+func (ls *ListService) update(ctx context.Context, s *txScope, listId string, details ListDetails) (db.ShoppingList, error) {
+	list, err := s.listRepo.FindById(ctx, listId)
 	if err != nil {
 		return db.ShoppingList{}, fmt.Errorf("Failed to get list during updating: %w", err)
 	}
+	if details.IfVersion != nil && list.Version != *details.IfVersion {
+		return db.ShoppingList{}, ErrVersionConflict
+	}
+	err = ls.apply(ctx, &list, details)
+	if err != nil {
+		return db.ShoppingList{}, err
+	}
 
-	err = ls.listRepo.UpdateStatus(ctx, listId, status)
+	err = s.listRepo.Update(ctx, listId, list.Status, list.Title, list.Notes, list.PlannedDate, list.Store, list.ArchivedAt)
 	if err != nil {
 		return db.ShoppingList{}, fmt.Errorf("Failed to update list: %w", err)
 	}
 
-	list, err := ls.FindById(ctx, listId)
+	list, err = s.listRepo.FindById(ctx, listId)
 	if err != nil {
 		return db.ShoppingList{}, fmt.Errorf("Failed to get list after updating: %w", err)
 	}
 
-	go func() {
-		// FIXME: check error response
-		_ = ls.eventHub.Publish(events.NewListUpdatedEvent(list.ID))
-	}()
+	s.publish(events.NewListUpdatedEvent(list))
 	return list, nil
 }
+
+// Delete deletes the list with all of its items. If ifVersion is set, the list is only deleted if it still has this
+// version; otherwise, ErrVersionConflict is returned.
+func (ls *ListService) Delete(ctx context.Context, listId string, ifVersion *int) error {
+	return ls.tx.run(ctx, func(s *txScope) error {
+		list, err := s.listRepo.FindById(ctx, listId)
+		if err != nil {
+			return fmt.Errorf("Failed to get list to be deleted: %w", err)
+		}
+		if ifVersion != nil && list.Version != *ifVersion {
+			return ErrVersionConflict
+		}
+		err = s.listRepo.Delete(ctx, listId)
+		if err != nil {
+			return err
+		}
+		s.publish(events.NewListDeletedEvent(listId))
+		return nil
+	})
+}
+
+// CopyMode describes which items are copied by ListService.Copy.
+type CopyMode string
+
+const (
+	// CopyAll copies all items of the list
+	CopyAll CopyMode = "all"
+	// CopyUnchecked copies the items that were not checked yet, e.g. to carry them over to the next shopping trip
+	CopyUnchecked CopyMode = "unchecked"
+)
+
+// Copy creates a new list with the items of the list, keeping their nesting and their order. The new list takes over
+// the title, the notes and the store of the list, unless they are set in details.
+func (ls *ListService) Copy(ctx context.Context, listId string, mode CopyMode, details ListDetails) (db.ShoppingList, error) {
+	if mode != CopyAll && mode != CopyUnchecked {
+		return db.ShoppingList{}, fmt.Errorf("%w: invalid copy mode %s", ErrInvalidList, mode)
+	}
+
+	var list db.ShoppingList
+	err := ls.tx.run(ctx, func(s *txScope) error {
+		source, err := s.listRepo.FindById(ctx, listId)
+		if err != nil {
+			return fmt.Errorf("Failed to get list to be copied: %w", err)
+		}
+		list = db.ShoppingList{
+			Status: "todo",
+			Title:  source.Title,
+			Notes:  source.Notes,
+			Store:  source.Store,
+		}
+		err = ls.apply(ctx, &list, details)
+		if err != nil {
+			return err
+		}
+		if list.ArchivedAt != nil {
+			return fmt.Errorf("%w: new lists can not be archived", ErrInvalidList)
+		}
+
+		list, err = s.listRepo.Create(ctx, list.Status, list.Title, list.Notes, list.PlannedDate, list.Store)
+		if err != nil {
+			return fmt.Errorf("Error creating list: %w", err)
+		}
+		items, err := s.itemRepo.FindAllByListId(ctx, listId)
+		if err != nil {
+			return fmt.Errorf("Failed to get items to be copied: %w", err)
+		}
+		err = copyItems(ctx, s, items, list.ID, mode == CopyUnchecked)
+		if err != nil {
+			return err
+		}
+		s.publish(events.NewListCreatedEvent(list))
+		return nil
+	})
+	return list, err
+}
+
+// PurgeArchived deletes all lists that were archived before the given time, and returns the number of deleted lists.
+func (ls *ListService) PurgeArchived(ctx context.Context, before time.Time) (int, error) {
+	ids, err := ls.listRepo.FindArchivedBefore(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		err := ls.Delete(ctx, id, nil)
+		if err != nil {
+			return i, fmt.Errorf("failed to purge archived list %s: %w", id, err)
+		}
+	}
+	return len(ids), nil
+}
+
+// RunRetention purges lists that were archived longer than retention ago, every interval, until ctx is done.
+func (ls *ListService) RunRetention(ctx context.Context, retention time.Duration, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := ls.PurgeArchived(ctx, time.Now().Add(-retention))
+		if err != nil {
+			slog.Error("failed to purge archived lists", "err", err)
+		} else if purged > 0 {
+			slog.Info("purged archived lists", "count", purged)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
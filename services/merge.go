@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/craftamap/shopping-list/db"
+	"github.com/craftamap/shopping-list/events"
+)
+
+// DuplicateMerge describes how duplicate items are merged. Item is the item the duplicates are merged into, as it
+// looks after merging, and Merged are the duplicates that are removed.
+type DuplicateMerge struct {
+	Item   db.ShoppingListItem   `json:"item"`
+	Merged []db.ShoppingListItem `json:"merged"`
+}
+
+// normalizeText returns the text used to compare items, ignoring case and whitespace.
+func normalizeText(text string) string {
+	return db.NormalizeText(text)
+}
+
+// duplicateGroup contains duplicate items whose quantities can be summed up.
+type duplicateGroup struct {
+	baseUnit string
+	items    []db.ShoppingListItem
+}
+
+// findDuplicateMerges finds unchecked items with equivalent text anywhere in the list. Items that have children are
+// never merged, as they group other items. Duplicates are only merged if their quantities can be summed up; items
+// without a quantity are merged into the first group of their text.
+func findDuplicateMerges(items []db.ShoppingListItem) []DuplicateMerge {
+	itemsById := map[string]db.ShoppingListItem{}
+	positions := map[string]int{}
+	hasChildren := map[string]bool{}
+	for i, item := range items {
+		itemsById[item.ID] = item
+		positions[item.ID] = i
+		if item.Parent != nil {
+			hasChildren[*item.Parent] = true
+		}
+	}
+
+	texts := []string{}
+	groupsByText := map[string][]*duplicateGroup{}
+	withoutQuantity := map[string][]db.ShoppingListItem{}
+	for _, item := range items {
+		text := normalizeText(item.Text)
+		if item.Checked || hasChildren[item.ID] || text == "" {
+			continue
+		}
+		if _, ok := groupsByText[text]; !ok {
+			texts = append(texts, text)
+			groupsByText[text] = []*duplicateGroup{}
+		}
+		if item.Quantity == nil {
+			withoutQuantity[text] = append(withoutQuantity[text], item)
+			continue
+		}
+		_, baseUnit := ToBaseUnit(*item.Quantity, item.Unit)
+		i := slices.IndexFunc(groupsByText[text], func(g *duplicateGroup) bool { return g.baseUnit == baseUnit })
+		if i == -1 {
+			groupsByText[text] = append(groupsByText[text], &duplicateGroup{baseUnit: baseUnit})
+			i = len(groupsByText[text]) - 1
+		}
+		groupsByText[text][i].items = append(groupsByText[text][i].items, item)
+	}
+
+	merges := []DuplicateMerge{}
+	for _, text := range texts {
+		groups := groupsByText[text]
+		if len(groups) == 0 {
+			groups = append(groups, &duplicateGroup{})
+		}
+		groups[0].items = append(groups[0].items, withoutQuantity[text]...)
+		for _, group := range groups {
+			if len(group.items) < 2 {
+				continue
+			}
+			// keep the first item of the list, as items are ordered by sort
+			slices.SortFunc(group.items, func(a, b db.ShoppingListItem) int {
+				return positions[a.ID] - positions[b.ID]
+			})
+			merges = append(merges, mergeGroup(group, itemsById))
+		}
+	}
+	return merges
+}
+
+// mergeGroup merges the items of the group into the first one. If all items have the same unit, the quantities are
+// summed up in this unit; otherwise, they are summed up in the base unit.
+func mergeGroup(group *duplicateGroup, itemsById map[string]db.ShoppingListItem) DuplicateMerge {
+	merged := group.items[0]
+
+	var units []*string
+	for _, item := range group.items {
+		if item.Quantity != nil {
+			units = append(units, item.Unit)
+		}
+	}
+	sameUnit := len(units) > 0 && !slices.ContainsFunc(units, func(u *string) bool {
+		return (u == nil) != (units[0] == nil) || (u != nil && *u != *units[0])
+	})
+
+	if len(units) > 0 {
+		sum := 0.0
+		for _, item := range group.items {
+			if item.Quantity == nil {
+				continue
+			}
+			if sameUnit {
+				sum += *item.Quantity
+			} else {
+				quantity, _ := ToBaseUnit(*item.Quantity, item.Unit)
+				sum += quantity
+			}
+		}
+		// avoid floating point noise like 0.30000000000000004
+		sum = math.Round(sum*1000) / 1000
+		merged.Quantity = &sum
+		// the first item may have no quantity, and therefore no unit
+		if sameUnit {
+			merged.Unit = units[0]
+		} else {
+			merged.Unit = nil
+			if group.baseUnit != "" {
+				baseUnit := group.baseUnit
+				merged.Unit = &baseUnit
+			}
+		}
+	}
+
+	parents := []string{}
+	for _, item := range group.items {
+		if item.Parent == nil {
+			continue
+		}
+		parent, ok := itemsById[*item.Parent]
+		if ok && !slices.Contains(parents, parent.Text) {
+			parents = append(parents, parent.Text)
+		}
+	}
+	if len(parents) > 0 {
+		note := "from " + strings.Join(parents, ", ")
+		merged.Note = &note
+	}
+
+	return DuplicateMerge{
+		Item:   merged,
+		Merged: group.items[1:],
+	}
+}
+
+// PreviewMergeDuplicates returns how MergeDuplicates would merge the duplicate items of the list, without changing
+// anything.
+func (is *ItemService) PreviewMergeDuplicates(ctx context.Context, listId string) ([]DuplicateMerge, error) {
+	items, err := is.FindAllByListId(ctx, listId)
+	if err != nil {
+		return nil, err
+	}
+	return findDuplicateMerges(items), nil
+}
+
+// MergeDuplicates merges unchecked items with equivalent text into the first of them, summing up their quantities,
+// and deletes the others. The merged item keeps a note of the groups the duplicates came from.
+func (is *ItemService) MergeDuplicates(ctx context.Context, listId string) ([]DuplicateMerge, error) {
+	var merges []DuplicateMerge
+	err := is.tx.run(ctx, func(s *txScope) error {
+		_, err := s.listRepo.FindById(ctx, listId)
+		if err != nil {
+			return fmt.Errorf("failed to get list while merging duplicates: %w", err)
+		}
+		items, err := s.itemRepo.FindAllByListId(ctx, listId)
+		if err != nil {
+			return fmt.Errorf("failed to get items while merging duplicates: %w", err)
+		}
+
+		merges = findDuplicateMerges(items)
+		itemsById := map[string]db.ShoppingListItem{}
+		for _, item := range items {
+			itemsById[item.ID] = item
+		}
+		for i, merge := range merges {
+			item := merge.Item
+			err := s.itemRepo.Update(ctx, item.ID, item.Text, item.Checked, item.Quantity, item.Unit, item.Note, item.Category)
+			if err != nil {
+				return fmt.Errorf("failed to update merged item: %w", err)
+			}
+			merges[i].Item, err = s.itemRepo.FindByID(ctx, item.ID)
+			if err != nil {
+				return fmt.Errorf("failed to get item after merging: %w", err)
+			}
+			oldItem := itemsById[item.ID]
+			err = s.recordItemChange(ctx, HistoryOperationUpdate, &oldItem, &merges[i].Item)
+			if err != nil {
+				return err
+			}
+			s.publishItemEvent(events.NewItemUpdatedEvent(merges[i].Item))
+
+			for _, duplicate := range merge.Merged {
+				err := s.itemRepo.Delete(ctx, duplicate.ID)
+				if err != nil {
+					return fmt.Errorf("failed to delete merged item: %w", err)
+				}
+				err = s.recordItemChange(ctx, HistoryOperationDelete, &duplicate, nil)
+				if err != nil {
+					return err
+				}
+				s.publishItemEvent(events.NewItemDeletedEvent(duplicate))
+			}
+		}
+		return nil
+	})
+	return merges, err
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/craftamap/shopping-list/db"
+)
+
+func TestFindDuplicateMerges(t *testing.T) {
+	type wantMerge struct {
+		id       string
+		quantity *float64
+		unit     *string
+		note     *string
+		merged   []string
+	}
+	tests := []struct {
+		name  string
+		items []db.ShoppingListItem
+		want  []wantMerge
+	}{
+		{
+			name: "no duplicates",
+			items: []db.ShoppingListItem{
+				{ID: "1", Text: "milk"},
+				{ID: "2", Text: "bread"},
+			},
+			want: []wantMerge{},
+		},
+		{
+			name: "text ignores case and whitespace",
+			items: []db.ShoppingListItem{
+				{ID: "1", Text: "Oat  Milk"},
+				{ID: "2", Text: "oat milk "},
+			},
+			want: []wantMerge{{id: "1", merged: []string{"2"}}},
+		},
+		{
+			name: "same unit is kept",
+			items: []db.ShoppingListItem{
+				{ID: "1", Text: "eggs", Quantity: ptr(3.0)},
+				{ID: "2", Text: "eggs", Quantity: ptr(6.0)},
+			},
+			want: []wantMerge{{id: "1", quantity: ptr(9.0), merged: []string{"2"}}},
+		},
+		{
+			name: "different units are converted to the base unit",
+			items: []db.ShoppingListItem{
+				{ID: "1", Text: "milk", Quantity: ptr(1.0), Unit: ptr("l")},
+				{ID: "2", Text: "milk", Quantity: ptr(250.0), Unit: ptr("ml")},
+			},
+			want: []wantMerge{{id: "1", quantity: ptr(1250.0), unit: ptr("ml"), merged: []string{"2"}}},
+		},
+		{
+			name: "incompatible units are not merged",
+			items: []db.ShoppingListItem{
+				{ID: "1", Text: "sugar", Quantity: ptr(1.0), Unit: ptr("kg")},
+				{ID: "2", Text: "sugar", Quantity: ptr(1.0), Unit: ptr("l")},
+			},
+			want: []wantMerge{},
+		},
+		{
+			name: "items without quantity are merged into the first group",
+			items: []db.ShoppingListItem{
+				{ID: "1", Text: "flour"},
+				{ID: "2", Text: "flour", Quantity: ptr(500.0), Unit: ptr("g")},
+				{ID: "3", Text: "flour", Quantity: ptr(1.0), Unit: ptr("l")},
+			},
+			want: []wantMerge{{id: "1", quantity: ptr(500.0), unit: ptr("g"), merged: []string{"2"}}},
+		},
+		{
+			name: "checked items and items with children are skipped",
+			items: []db.ShoppingListItem{
+				{ID: "1", Text: "butter", Checked: true},
+				{ID: "2", Text: "butter"},
+				{ID: "3", Text: "cake"},
+				{ID: "4", Text: "butter", Parent: ptr("3")},
+				{ID: "5", Text: "cake"},
+			},
+			want: []wantMerge{{id: "2", note: ptr("from cake"), merged: []string{"4"}}},
+		},
+		{
+			name: "floating point noise is rounded",
+			items: []db.ShoppingListItem{
+				{ID: "1", Text: "cream", Quantity: ptr(0.1), Unit: ptr("l")},
+				{ID: "2", Text: "cream", Quantity: ptr(0.2), Unit: ptr("l")},
+			},
+			want: []wantMerge{{id: "1", quantity: ptr(0.3), unit: ptr("l"), merged: []string{"2"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merges := findDuplicateMerges(tt.items)
+			if len(merges) != len(tt.want) {
+				t.Fatalf("got %d merges, want %d: %+v", len(merges), len(tt.want), merges)
+			}
+			for i, merge := range merges {
+				want := tt.want[i]
+				if merge.Item.ID != want.id {
+					t.Errorf("merge %d: item = %s, want %s", i, merge.Item.ID, want.id)
+				}
+				if !equalValues(merge.Item.Quantity, want.quantity) {
+					t.Errorf("merge %d: quantity = %v, want %v", i, deref(merge.Item.Quantity), deref(want.quantity))
+				}
+				if !equalValues(merge.Item.Unit, want.unit) {
+					t.Errorf("merge %d: unit = %v, want %v", i, deref(merge.Item.Unit), deref(want.unit))
+				}
+				if !equalValues(merge.Item.Note, want.note) {
+					t.Errorf("merge %d: note = %v, want %v", i, deref(merge.Item.Note), deref(want.note))
+				}
+				merged := []string{}
+				for _, item := range merge.Merged {
+					merged = append(merged, item.ID)
+				}
+				if !slices.Equal(merged, want.merged) {
+					t.Errorf("merge %d: merged = %v, want %v", i, merged, want.merged)
+				}
+			}
+		})
+	}
+}
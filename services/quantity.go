@@ -0,0 +1,146 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// unitDefinition describes how a unit is converted into its base unit, so quantities in different units of the same
+// dimension can be summed up.
+type unitDefinition struct {
+	base   string
+	factor float64
+}
+
+var units = map[string]unitDefinition{
+	"mg":   {base: "g", factor: 0.001},
+	"g":    {base: "g", factor: 1},
+	"kg":   {base: "g", factor: 1000},
+	"ml":   {base: "ml", factor: 1},
+	"cl":   {base: "ml", factor: 10},
+	"dl":   {base: "ml", factor: 100},
+	"l":    {base: "ml", factor: 1000},
+	"pack": {base: "pack", factor: 1},
+	"can":  {base: "can", factor: 1},
+}
+
+// unitAliases maps the ways units are typed to the units above. An empty unit means the quantity is counted in
+// pieces.
+var unitAliases = map[string]string{
+	"mg": "mg", "milligram": "mg", "milligrams": "mg", "milligramm": "mg",
+	"g": "g", "gr": "g", "gram": "g", "grams": "g", "gramm": "g",
+	"kg": "kg", "kilo": "kg", "kilos": "kg", "kilogram": "kg", "kilograms": "kg", "kilogramm": "kg",
+	"ml": "ml", "milliliter": "ml", "milliliters": "ml", "millilitre": "ml", "millilitres": "ml",
+	"cl": "cl", "dl": "dl",
+	"l": "l", "liter": "l", "liters": "l", "litre": "l", "litres": "l",
+	"pack": "pack", "packs": "pack", "pkg": "pack", "packung": "pack", "packungen": "pack",
+	"can": "can", "cans": "can", "dose": "can", "dosen": "can",
+	"x": "", "×": "", "pc": "", "pcs": "", "piece": "", "pieces": "", "stk": "", "stück": "",
+}
+
+// quantityPattern matches a leading quantity like "3", "1,5" or "1/2", optionally followed by a word that might be a
+// unit, followed by the rest of the text. The whitespace between the quantity and the word is captured, as only known
+// units may be attached to the quantity, like in "500g".
+var quantityPattern = regexp.MustCompile(`^\s*(\d+(?:[.,]\d+)?|\d+/\d+)(\s*)([\p{L}×]+\.?)?\s+(\S.*)$`)
+
+// NormalizeUnit returns the canonical name of the unit, and whether the unit is known. Known units that mean pieces
+// are normalised to an empty string.
+func NormalizeUnit(unit string) (string, bool) {
+	normalized, ok := unitAliases[strings.TrimSuffix(strings.ToLower(strings.TrimSpace(unit)), ".")]
+	return normalized, ok
+}
+
+// ParseQuantity extracts a leading quantity and unit from typed text like "3x eggs" or "500 g butter". It returns the
+// remaining text, and the quantity and unit if found. Unit is nil if the quantity is counted in pieces.
+func ParseQuantity(text string) (string, *float64, *string) {
+	return parseQuantity(text, nil)
+}
+
+// parseQuantity works like ParseQuantity, but also accepts currentUnit as unit, even if it is not a known unit. This
+// way, editing the text of an item with an unit like "bunch", which is shown as "2 bunch parsley", keeps the unit.
+func parseQuantity(text string, currentUnit *string) (string, *float64, *string) {
+	match := quantityPattern.FindStringSubmatch(text)
+	if match == nil {
+		return text, nil, nil
+	}
+	quantity, ok := parseNumber(match[1])
+	if !ok || quantity == 0 {
+		return text, nil, nil
+	}
+
+	attached, word, rest := match[2] == "", match[3], strings.TrimSpace(match[4])
+	if word == "" {
+		return rest, &quantity, nil
+	}
+	unit, ok := NormalizeUnit(word)
+	if !ok && currentUnit != nil && strings.EqualFold(strings.TrimSuffix(word, "."), *currentUnit) {
+		unit, ok = *currentUnit, true
+	}
+	if !ok {
+		if attached {
+			// the number is part of a word, e.g. "7up zero" or "2nd bag"
+			return text, nil, nil
+		}
+		// the word is part of the text, e.g. "3 large eggs"
+		return word + " " + rest, &quantity, nil
+	}
+	if unit == "" {
+		return rest, &quantity, nil
+	}
+	return rest, &quantity, &unit
+}
+
+func parseNumber(s string) (float64, bool) {
+	numerator, denominator, isFraction := strings.Cut(s, "/")
+	if isFraction {
+		n, err := strconv.ParseFloat(numerator, 64)
+		if err != nil {
+			return 0, false
+		}
+		d, err := strconv.ParseFloat(denominator, 64)
+		if err != nil || d == 0 {
+			return 0, false
+		}
+		return n / d, true
+	}
+	f, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// ToBaseUnit converts the quantity into the base unit of its dimension, e.g. 0.5 kg into 500 g, so quantities of
+// duplicate items can be summed up. Unknown units are returned unchanged; an empty unit means pieces.
+func ToBaseUnit(quantity float64, unit *string) (float64, string) {
+	if unit == nil {
+		return quantity, ""
+	}
+	normalized, ok := NormalizeUnit(*unit)
+	if !ok {
+		return quantity, strings.ToLower(strings.TrimSpace(*unit))
+	}
+	definition, ok := units[normalized]
+	if !ok {
+		return quantity, normalized
+	}
+	return quantity * definition.factor, definition.base
+}
+
+// normalizeUnitInput normalises an unit sent by a client. Empty units and units meaning pieces are returned as nil,
+// unknown units are kept as they were sent.
+func normalizeUnitInput(unit *string) *string {
+	if unit == nil || strings.TrimSpace(*unit) == "" {
+		return nil
+	}
+	normalized, ok := NormalizeUnit(*unit)
+	if !ok {
+		trimmed := strings.TrimSpace(*unit)
+		return &trimmed
+	}
+	if normalized == "" {
+		return nil
+	}
+	return &normalized
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		text         string
+		wantText     string
+		wantQuantity *float64
+		wantUnit     *string
+	}{
+		{text: "eggs", wantText: "eggs"},
+		{text: "3x eggs", wantText: "eggs", wantQuantity: ptr(3.0)},
+		{text: "3 x eggs", wantText: "eggs", wantQuantity: ptr(3.0)},
+		{text: "3 eggs", wantText: "eggs", wantQuantity: ptr(3.0)},
+		{text: "500 g butter", wantText: "butter", wantQuantity: ptr(500.0), wantUnit: ptr("g")},
+		{text: "500g butter", wantText: "butter", wantQuantity: ptr(500.0), wantUnit: ptr("g")},
+		{text: "1,5 Liter milk", wantText: "milk", wantQuantity: ptr(1.5), wantUnit: ptr("l")},
+		{text: "1.5 kilo flour", wantText: "flour", wantQuantity: ptr(1.5), wantUnit: ptr("kg")},
+		{text: "1/2 l cream", wantText: "cream", wantQuantity: ptr(0.5), wantUnit: ptr("l")},
+		{text: "2 Stück bread", wantText: "bread", wantQuantity: ptr(2.0)},
+		{text: "3 large eggs", wantText: "large eggs", wantQuantity: ptr(3.0)},
+		{text: "  2   cans tomatoes", wantText: "tomatoes", wantQuantity: ptr(2.0), wantUnit: ptr("can")},
+		{text: "1/0 l milk", wantText: "1/0 l milk"},
+		{text: "7up", wantText: "7up"},
+		{text: "3", wantText: "3"},
+		{text: "7up zero", wantText: "7up zero"},
+		{text: "2nd bag", wantText: "2nd bag"},
+		{text: "7 up zero", wantText: "up zero", wantQuantity: ptr(7.0)},
+		{text: "0 eggs", wantText: "0 eggs"},
+		{text: "0,0 l milk", wantText: "0,0 l milk"},
+		{text: "2 bunch parsley", wantText: "bunch parsley", wantQuantity: ptr(2.0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			text, quantity, unit := ParseQuantity(tt.text)
+			if text != tt.wantText {
+				t.Errorf("text = %q, want %q", text, tt.wantText)
+			}
+			if !equalValues(quantity, tt.wantQuantity) {
+				t.Errorf("quantity = %v, want %v", deref(quantity), deref(tt.wantQuantity))
+			}
+			if !equalValues(unit, tt.wantUnit) {
+				t.Errorf("unit = %v, want %v", deref(unit), deref(tt.wantUnit))
+			}
+		})
+	}
+}
+
+func TestParseQuantityWithCurrentUnit(t *testing.T) {
+	tests := []struct {
+		text         string
+		currentUnit  *string
+		wantText     string
+		wantQuantity *float64
+		wantUnit     *string
+	}{
+		{text: "2 bunch parsley", currentUnit: ptr("bunch"), wantText: "parsley", wantQuantity: ptr(2.0), wantUnit: ptr("bunch")},
+		{text: "3 Bunch parsley", currentUnit: ptr("bunch"), wantText: "parsley", wantQuantity: ptr(3.0), wantUnit: ptr("bunch")},
+		{text: "2bunch parsley", currentUnit: ptr("bunch"), wantText: "parsley", wantQuantity: ptr(2.0), wantUnit: ptr("bunch")},
+		{text: "2 bunch parsley", currentUnit: ptr("head"), wantText: "bunch parsley", wantQuantity: ptr(2.0)},
+		{text: "500 g butter", currentUnit: ptr("bunch"), wantText: "butter", wantQuantity: ptr(500.0), wantUnit: ptr("g")},
+		{text: "parsley", currentUnit: ptr("bunch"), wantText: "parsley"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			text, quantity, unit := parseQuantity(tt.text, tt.currentUnit)
+			if text != tt.wantText {
+				t.Errorf("text = %q, want %q", text, tt.wantText)
+			}
+			if !equalValues(quantity, tt.wantQuantity) {
+				t.Errorf("quantity = %v, want %v", deref(quantity), deref(tt.wantQuantity))
+			}
+			if !equalValues(unit, tt.wantUnit) {
+				t.Errorf("unit = %v, want %v", deref(unit), deref(tt.wantUnit))
+			}
+		})
+	}
+}
+
+func TestNormalizeUnitInput(t *testing.T) {
+	tests := []struct {
+		name string
+		unit *string
+		want *string
+	}{
+		{name: "nil", unit: nil, want: nil},
+		{name: "empty", unit: ptr(""), want: nil},
+		{name: "blank", unit: ptr("  "), want: nil},
+		{name: "pieces", unit: ptr("pcs"), want: nil},
+		{name: "alias", unit: ptr(" Kilo "), want: ptr("kg")},
+		{name: "unknown", unit: ptr(" bunch "), want: ptr("bunch")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalizeUnitInput(tt.unit)
+			if !equalValues(got, tt.want) {
+				t.Errorf("normalizeUnitInput() = %v, want %v", deref(got), deref(tt.want))
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func deref[T any](p *T) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
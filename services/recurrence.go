@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/craftamap/shopping-list/db"
+)
+
+// RecurrenceFrequencies contains all valid values for the frequency of a recurrence.
+var RecurrenceFrequencies = []string{"weekly", "biweekly", "monthly"}
+
+// ErrInvalidRecurrence is returned if a recurrence to be saved is not valid.
+var ErrInvalidRecurrence = errors.New("invalid recurrence")
+
+// RecurrenceService creates lists on a schedule, using the ListService.
+type RecurrenceService struct {
+	recurrenceRepo *db.RecurrenceRepository
+	templateRepo   *db.TemplateRepository
+	listService    *ListService
+}
+
+func NewRecurrenceService(recurrenceRepo *db.RecurrenceRepository, templateRepo *db.TemplateRepository, listService *ListService) *RecurrenceService {
+	return &RecurrenceService{
+		recurrenceRepo: recurrenceRepo,
+		templateRepo:   templateRepo,
+		listService:    listService,
+	}
+}
+
+// occurrence returns the n-th run of a recurrence starting at start. Monthly runs on days that do not exist in a
+// month, e.g. the 31st, happen on the last day of the month instead.
+func occurrence(start time.Time, frequency string, n int) time.Time {
+	switch frequency {
+	case "biweekly":
+		return start.AddDate(0, 0, 14*n)
+	case "monthly":
+		year, month, day := start.Date()
+		firstOfMonth := time.Date(year, month+time.Month(n), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+		lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+		return firstOfMonth.AddDate(0, 0, min(day, lastDay)-1)
+	default:
+		return start.AddDate(0, 0, 7*n)
+	}
+}
+
+// surroundingOccurrences returns the last run of the recurrence at or before t, which is the zero time if there is
+// none, and the first run after t.
+func surroundingOccurrences(start time.Time, frequency string, t time.Time) (time.Time, time.Time) {
+	var previous time.Time
+	for n := 0; ; n++ {
+		next := occurrence(start, frequency, n)
+		if next.After(t) {
+			return previous, next
+		}
+		previous = next
+	}
+}
+
+func (rs *RecurrenceService) GetAll(ctx context.Context) ([]db.Recurrence, error) {
+	recurrences, err := rs.recurrenceRepo.FindAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting recurrences: %w", err)
+	}
+	return recurrences, nil
+}
+
+// Create creates a recurrence whose first run is at start, formatted as RFC3339. If start is in the past, the first
+// run is the next one after now. If template is nil, lists are created from the unchecked items of the previous list.
+func (rs *RecurrenceService) Create(ctx context.Context, title string, frequency string, start string, template *string) (db.Recurrence, error) {
+	title = strings.TrimSpace(title)
+	if utf8.RuneCountInString(title) > maxListTitleLength {
+		return db.Recurrence{}, fmt.Errorf("%w: title must not be longer than %d characters", ErrInvalidRecurrence, maxListTitleLength)
+	}
+	if !slices.Contains(RecurrenceFrequencies, frequency) {
+		return db.Recurrence{}, fmt.Errorf("%w: invalid frequency", ErrInvalidRecurrence)
+	}
+	startTime, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		return db.Recurrence{}, fmt.Errorf("%w: start must be formatted as RFC3339", ErrInvalidRecurrence)
+	}
+	template = emptyToNil(template)
+	if template != nil {
+		_, err := rs.templateRepo.FindById(ctx, *template)
+		if errors.Is(err, sql.ErrNoRows) {
+			return db.Recurrence{}, fmt.Errorf("%w: unknown template %s", ErrInvalidRecurrence, *template)
+		}
+		if err != nil {
+			return db.Recurrence{}, fmt.Errorf("failed to get template: %w", err)
+		}
+	}
+
+	_, nextRun := surroundingOccurrences(startTime, frequency, time.Now())
+	if startTime.After(time.Now()) {
+		nextRun = startTime
+	}
+	recurrence, err := rs.recurrenceRepo.Create(ctx, title, frequency, startTime.Format(time.RFC3339), nextRun.Format(time.RFC3339), template)
+	if err != nil {
+		return db.Recurrence{}, fmt.Errorf("Error creating recurrence: %w", err)
+	}
+	return recurrence, nil
+}
+
+// Delete deletes the recurrence; lists it created are kept.
+func (rs *RecurrenceService) Delete(ctx context.Context, recurrenceId string) error {
+	return rs.recurrenceRepo.Delete(ctx, recurrenceId)
+}
+
+// RunDue creates the lists of all recurrences whose next run is due at now, and returns the number of created lists.
+// If several runs of a recurrence were missed, e.g. because the server was down, only one list is created for the
+// latest of them.
+func (rs *RecurrenceService) RunDue(ctx context.Context, now time.Time) (int, error) {
+	recurrences, err := rs.recurrenceRepo.FindAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, recurrence := range recurrences {
+		nextRun, err := time.Parse(time.RFC3339, recurrence.NextRun)
+		if err != nil {
+			return created, fmt.Errorf("invalid next run of recurrence %s: %w", recurrence.ID, err)
+		}
+		if nextRun.After(now) {
+			continue
+		}
+		start, err := time.Parse(time.RFC3339, recurrence.Start)
+		if err != nil {
+			return created, fmt.Errorf("invalid start of recurrence %s: %w", recurrence.ID, err)
+		}
+
+		due, newNextRun := surroundingOccurrences(start, recurrence.Frequency, now)
+		// claiming the run first makes sure that it is only run once, even with multiple server instances; if
+		// creating the list fails, the run is skipped
+		claimed, err := rs.recurrenceRepo.ClaimRun(ctx, recurrence.ID, recurrence.NextRun, newNextRun.Format(time.RFC3339))
+		if err != nil {
+			return created, err
+		}
+		if !claimed {
+			continue
+		}
+		list, err := rs.run(ctx, recurrence, due)
+		if err != nil {
+			slog.Error("failed to run recurrence", "recurrence", recurrence.ID, "err", err)
+			continue
+		}
+		err = rs.recurrenceRepo.SetLastList(ctx, recurrence.ID, list.ID)
+		if err != nil {
+			return created, err
+		}
+		created++
+	}
+	return created, nil
+}
+
+// run creates the list of the recurrence, planned for the day it is due.
+func (rs *RecurrenceService) run(ctx context.Context, recurrence db.Recurrence, due time.Time) (db.ShoppingList, error) {
+	plannedDate := due.Format(time.DateOnly)
+	details := ListDetails{
+		Title:       &recurrence.Title,
+		PlannedDate: &plannedDate,
+	}
+	if recurrence.Template != nil {
+		return rs.listService.CreateFromTemplates(ctx, details, []string{*recurrence.Template})
+	}
+	if recurrence.LastList != nil {
+		return rs.listService.Copy(ctx, *recurrence.LastList, CopyUnchecked, details)
+	}
+	return rs.listService.Create(ctx, details)
+}
+
+// Run creates the lists of due recurrences every interval, until ctx is done. Runs that were missed while the server
+// was down are caught up immediately.
+func (rs *RecurrenceService) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		created, err := rs.RunDue(ctx, time.Now())
+		if err != nil {
+			slog.Error("failed to run recurrences", "err", err)
+		} else if created > 0 {
+			slog.Info("created recurring lists", "count", created)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOccurrence(t *testing.T) {
+	tests := []struct {
+		name      string
+		start     string
+		frequency string
+		n         int
+		want      string
+	}{
+		{name: "first run", start: "2026-01-03T08:00:00Z", frequency: "weekly", n: 0, want: "2026-01-03T08:00:00Z"},
+		{name: "weekly", start: "2026-01-03T08:00:00Z", frequency: "weekly", n: 5, want: "2026-02-07T08:00:00Z"},
+		{name: "biweekly", start: "2026-01-03T08:00:00Z", frequency: "biweekly", n: 2, want: "2026-01-31T08:00:00Z"},
+		{name: "monthly", start: "2026-01-15T08:00:00Z", frequency: "monthly", n: 1, want: "2026-02-15T08:00:00Z"},
+		{name: "monthly across years", start: "2026-11-15T08:00:00Z", frequency: "monthly", n: 3, want: "2027-02-15T08:00:00Z"},
+		{name: "monthly clamped to end of february", start: "2026-01-31T08:00:00Z", frequency: "monthly", n: 1, want: "2026-02-28T08:00:00Z"},
+		{name: "monthly clamped in leap year", start: "2028-01-31T08:00:00Z", frequency: "monthly", n: 1, want: "2028-02-29T08:00:00Z"},
+		{name: "monthly clamped to 30th", start: "2026-01-31T08:00:00Z", frequency: "monthly", n: 3, want: "2026-04-30T08:00:00Z"},
+		{name: "monthly back to 31st after clamping", start: "2026-01-31T08:00:00Z", frequency: "monthly", n: 2, want: "2026-03-31T08:00:00Z"},
+		{name: "monthly keeps offset", start: "2026-01-31T08:00:00+01:00", frequency: "monthly", n: 1, want: "2026-02-28T08:00:00+01:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, err := time.Parse(time.RFC3339, tt.start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := occurrence(start, tt.frequency, tt.n).Format(time.RFC3339)
+			if got != tt.want {
+				t.Errorf("occurrence() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSurroundingOccurrences(t *testing.T) {
+	tests := []struct {
+		name         string
+		start        string
+		frequency    string
+		t            string
+		wantPrevious string
+		wantNext     string
+	}{
+		{name: "before start", start: "2026-01-03T08:00:00Z", frequency: "weekly", t: "2026-01-01T00:00:00Z", wantPrevious: "0001-01-01T00:00:00Z", wantNext: "2026-01-03T08:00:00Z"},
+		{name: "at start", start: "2026-01-03T08:00:00Z", frequency: "weekly", t: "2026-01-03T08:00:00Z", wantPrevious: "2026-01-03T08:00:00Z", wantNext: "2026-01-10T08:00:00Z"},
+		{name: "between runs", start: "2026-01-03T08:00:00Z", frequency: "biweekly", t: "2026-01-20T12:00:00Z", wantPrevious: "2026-01-17T08:00:00Z", wantNext: "2026-01-31T08:00:00Z"},
+		{name: "monthly end of month", start: "2026-01-31T08:00:00Z", frequency: "monthly", t: "2026-03-01T00:00:00Z", wantPrevious: "2026-02-28T08:00:00Z", wantNext: "2026-03-31T08:00:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, err := time.Parse(time.RFC3339, tt.start)
+			if err != nil {
+				t.Fatal(err)
+			}
+			at, err := time.Parse(time.RFC3339, tt.t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			previous, next := surroundingOccurrences(start, tt.frequency, at)
+			if got := previous.Format(time.RFC3339); got != tt.wantPrevious {
+				t.Errorf("previous = %s, want %s", got, tt.wantPrevious)
+			}
+			if got := next.Format(time.RFC3339); got != tt.wantNext {
+				t.Errorf("next = %s, want %s", got, tt.wantNext)
+			}
+		})
+	}
+}
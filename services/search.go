@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/craftamap/shopping-list/db"
+)
+
+// ErrInvalidSearch is returned if a search query is empty.
+var ErrInvalidSearch = errors.New("invalid search")
+
+// ItemSearchResult is an item matching a search, together with its list.
+type ItemSearchResult struct {
+	Item db.ShoppingListItem `json:"item"`
+	List db.ShoppingList     `json:"list"`
+	// Path contains the texts of the ancestors of the item, starting with the top level one
+	Path []string `json:"path"`
+}
+
+// SearchResult contains the items and the lists matching a search, best matches first.
+type SearchResult struct {
+	Items []ItemSearchResult `json:"items"`
+	Lists []db.ShoppingList  `json:"lists"`
+}
+
+type SearchService struct {
+	listRepo *db.ListRepository
+	itemRepo *db.ItemRepository
+}
+
+func NewSearchService(listRepo *db.ListRepository, itemRepo *db.ItemRepository) *SearchService {
+	return &SearchService{
+		listRepo: listRepo,
+		itemRepo: itemRepo,
+	}
+}
+
+// ftsQuery turns the words typed by users into a FTS5 query matching texts containing words that start with each of
+// them. The words are quoted, so characters with a meaning in FTS5 queries are searched for as they are.
+func ftsQuery(query string) string {
+	terms := []string{}
+	for _, word := range strings.Fields(query) {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// Search returns up to limit items and up to limit lists of all lists, including archived ones, whose item text or
+// list title and notes contain all words of the query, ignoring case and diacritics.
+func (ss *SearchService) Search(ctx context.Context, query string, limit int) (SearchResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return SearchResult{}, fmt.Errorf("%w: query must not be empty", ErrInvalidSearch)
+	}
+
+	items, err := ss.itemRepo.Search(ctx, ftsQuery(query), limit)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	lists, err := ss.listRepo.Search(ctx, ftsQuery(query), limit)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	listsById := map[string]db.ShoppingList{}
+	itemsById := map[string]db.ShoppingListItem{}
+	result := SearchResult{
+		Items: []ItemSearchResult{},
+		Lists: lists,
+	}
+	for _, item := range items {
+		list, ok := listsById[item.List]
+		if !ok {
+			list, err = ss.listRepo.FindById(ctx, item.List)
+			if err != nil {
+				return SearchResult{}, fmt.Errorf("failed to get list of found item: %w", err)
+			}
+			listsById[item.List] = list
+		}
+
+		path := []string{}
+		for parentId := item.Parent; parentId != nil; {
+			parent, ok := itemsById[*parentId]
+			if !ok {
+				parent, err = ss.itemRepo.FindByID(ctx, *parentId)
+				if err != nil {
+					return SearchResult{}, fmt.Errorf("failed to get parent of found item: %w", err)
+				}
+				itemsById[*parentId] = parent
+			}
+			path = append(path, parent.Text)
+			parentId = parent.Parent
+		}
+		slices.Reverse(path)
+
+		result.Items = append(result.Items, ItemSearchResult{
+			Item: item,
+			List: list,
+			Path: path,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/craftamap/shopping-list/db"
+)
+
+// suggestionCandidateFactor determines how many candidates per requested suggestion are preselected by the number of
+// lists they were used on, before they are ranked by score.
+const suggestionCandidateFactor = 5
+
+// suggestionHalfLife is the age after which an usage of an item counts half as much when ranking suggestions.
+const suggestionHalfLife = 30 * 24 * time.Hour
+
+// Suggestion is an item that was used on previous lists.
+type Suggestion struct {
+	// Text is the text of the item as it was last used, with whitespace collapsed
+	Text string `json:"text"`
+	// Quantity, Unit and Category are the ones last used with the item, if any
+	Quantity *float64 `json:"quantity"`
+	Unit     *string  `json:"unit"`
+	Category *string  `json:"category"`
+	// Count is the number of lists the item was used on
+	Count int `json:"count"`
+	// LastUsed is the date of the last list the item was used on
+	LastUsed string `json:"lastUsed"`
+
+	score float64
+}
+
+type SuggestionService struct {
+	listRepo *db.ListRepository
+	itemRepo *db.ItemRepository
+}
+
+func NewSuggestionService(listRepo *db.ListRepository, itemRepo *db.ItemRepository) *SuggestionService {
+	return &SuggestionService{
+		listRepo: listRepo,
+		itemRepo: itemRepo,
+	}
+}
+
+// matchesQuery returns whether the normalised text of an item matches the normalised query, i.e. whether each word
+// of the query is the beginning of a word of the text.
+func matchesQuery(text string, query string) bool {
+	words := strings.Fields(text)
+	for _, queryWord := range strings.Fields(query) {
+		if !slices.ContainsFunc(words, func(word string) bool { return strings.HasPrefix(word, queryWord) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// Suggest returns up to limit items used on previous lists that match the query, ignoring case and whitespace. Items
+// are ranked by how often they were used, with recent usages counting more than old ones.
+func (ss *SuggestionService) Suggest(ctx context.Context, query string, limit int) ([]Suggestion, error) {
+	query = normalizeText(query)
+	candidates, err := ss.itemRepo.FindSuggestionCandidates(ctx, strings.Fields(query), limit*suggestionCandidateFactor)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting suggestion candidates: %w", err)
+	}
+	usages, err := ss.itemRepo.FindUsagesByNormalizedText(ctx, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting item usages: %w", err)
+	}
+
+	now := time.Now()
+	suggestionsByText := map[string]*Suggestion{}
+	// lists on which an item was used, to count an item that is contained twice in a list only once
+	lists := map[string]map[string]bool{}
+	for _, usage := range usages {
+		text := normalizeText(usage.Text)
+		if text == "" || !matchesQuery(text, query) {
+			continue
+		}
+		suggestion, ok := suggestionsByText[text]
+		if !ok {
+			suggestion = &Suggestion{}
+			suggestionsByText[text] = suggestion
+			lists[text] = map[string]bool{}
+		}
+
+		// usages are ordered by date, so later usages replace the details of earlier ones
+		suggestion.Text = strings.Join(strings.Fields(usage.Text), " ")
+		suggestion.LastUsed = usage.ListDate
+		if usage.Quantity != nil {
+			suggestion.Quantity, suggestion.Unit = usage.Quantity, usage.Unit
+		}
+		if usage.Category != nil {
+			suggestion.Category = usage.Category
+		}
+		if lists[text][usage.List] {
+			continue
+		}
+		lists[text][usage.List] = true
+		suggestion.Count++
+		date, err := time.Parse(time.RFC3339, usage.ListDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date of list %s: %w", usage.List, err)
+		}
+		suggestion.score += math.Pow(0.5, max(now.Sub(date), 0).Hours()/suggestionHalfLife.Hours())
+	}
+
+	suggestions := make([]Suggestion, 0, len(suggestionsByText))
+	for _, suggestion := range suggestionsByText {
+		suggestions = append(suggestions, *suggestion)
+	}
+	slices.SortFunc(suggestions, func(a, b Suggestion) int {
+		return cmp.Or(
+			cmp.Compare(b.score, a.score),
+			strings.Compare(b.LastUsed, a.LastUsed),
+			strings.Compare(a.Text, b.Text),
+		)
+	})
+	return suggestions[:min(limit, len(suggestions))], nil
+}
+
+// minPurchasesForDue is the number of purchases needed before the interval between purchases of an item is guessed.
+const minPurchasesForDue = 3
+
+// dueFactor is the part of the usual interval between purchases after which an item is considered to be due, so it is
+// also suggested for trips that are a bit earlier than usual.
+const dueFactor = 0.8
+
+// DueItem is an item that is bought regularly, and is probably due to be bought again.
+type DueItem struct {
+	// Text is the text of the item as it was last bought, with whitespace collapsed
+	Text string `json:"text"`
+	// Quantity, Unit and Category are the ones last used when the item was bought, if any
+	Quantity *float64 `json:"quantity"`
+	Unit     *string  `json:"unit"`
+	Category *string  `json:"category"`
+	// Purchases is the number of days the item was bought on
+	Purchases int `json:"purchases"`
+	// IntervalDays is the median number of days between purchases
+	IntervalDays float64 `json:"intervalDays"`
+	// LastPurchased is the day the item was last bought, formatted as YYYY-MM-DD
+	LastPurchased string `json:"lastPurchased"`
+	// DaysSinceLastPurchase is the number of days between the last purchase and the day of the list
+	DaysSinceLastPurchase int `json:"daysSinceLastPurchase"`
+}
+
+// median returns the median of the sorted values.
+func median(values []int) float64 {
+	middle := len(values) / 2
+	if len(values)%2 == 0 {
+		return float64(values[middle-1]+values[middle]) / 2
+	}
+	return float64(values[middle])
+}
+
+// daysBetween returns the number of calendar days from a to b.
+func daysBetween(a time.Time, b time.Time) int {
+	a = time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	b = time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(math.Round(b.Sub(a).Hours() / 24))
+}
+
+// ProbablyDue returns items that are not on the list yet, but are probably due on the day of the list: the planned
+// date, or today if it is not set. An item counts as bought on the day of a list if it was checked on that list;
+// items bought regularly are due once most of the usual interval between their purchases has passed. Items are
+// ordered by how overdue they are.
+func (ss *SuggestionService) ProbablyDue(ctx context.Context, listId string) ([]DueItem, error) {
+	list, err := ss.listRepo.FindById(ctx, listId)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting list while finding due items: %w", err)
+	}
+	day := time.Now()
+	if list.PlannedDate != nil {
+		day, err = time.ParseInLocation(time.DateOnly, *list.PlannedDate, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid planned date of list %s: %w", listId, err)
+		}
+	}
+	// lists of the same day only count as one purchase, so items bought on fewer lists cannot be due
+	usages, err := ss.itemRepo.FindPurchases(ctx, listId, minPurchasesForDue)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting item purchases: %w", err)
+	}
+
+	texts := []string{}
+	purchases := map[string][]time.Time{}
+	lastPurchases := map[string]db.ItemUsage{}
+	for _, usage := range usages {
+		text := normalizeText(usage.Text)
+		date, err := time.Parse(time.RFC3339, usage.ListDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date of list %s: %w", usage.List, err)
+		}
+		date = date.Local()
+		if _, ok := purchases[text]; !ok {
+			texts = append(texts, text)
+		}
+		// usages are ordered by date, so buying an item twice on a day only counts once
+		dates := purchases[text]
+		if len(dates) == 0 || daysBetween(dates[len(dates)-1], date) > 0 {
+			purchases[text] = append(dates, date)
+		}
+		// keep the last quantity and category, even if the item was bought without one later on
+		last := lastPurchases[text]
+		if usage.Quantity == nil {
+			usage.Quantity, usage.Unit = last.Quantity, last.Unit
+		}
+		if usage.Category == nil {
+			usage.Category = last.Category
+		}
+		lastPurchases[text] = usage
+	}
+
+	dueItems := []DueItem{}
+	for _, text := range texts {
+		dates := purchases[text]
+		if len(dates) < minPurchasesForDue {
+			continue
+		}
+		intervals := []int{}
+		for i := 1; i < len(dates); i++ {
+			intervals = append(intervals, daysBetween(dates[i-1], dates[i]))
+		}
+		slices.Sort(intervals)
+		interval := median(intervals)
+
+		lastPurchased := dates[len(dates)-1]
+		daysSince := daysBetween(lastPurchased, day)
+		if float64(daysSince) < interval*dueFactor {
+			continue
+		}
+		last := lastPurchases[text]
+		dueItems = append(dueItems, DueItem{
+			Text:                  strings.Join(strings.Fields(last.Text), " "),
+			Quantity:              last.Quantity,
+			Unit:                  last.Unit,
+			Category:              last.Category,
+			Purchases:             len(dates),
+			IntervalDays:          interval,
+			LastPurchased:         lastPurchased.Format(time.DateOnly),
+			DaysSinceLastPurchase: daysSince,
+		})
+	}
+	slices.SortFunc(dueItems, func(a, b DueItem) int {
+		return cmp.Or(
+			cmp.Compare(float64(b.DaysSinceLastPurchase)/b.IntervalDays, float64(a.DaysSinceLastPurchase)/a.IntervalDays),
+			strings.Compare(a.Text, b.Text),
+		)
+	})
+	return dueItems, nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMedian(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int
+		want   float64
+	}{
+		{name: "single value", values: []int{7}, want: 7},
+		{name: "odd count", values: []int{6, 7, 30}, want: 7},
+		{name: "even count", values: []int{6, 7, 8, 30}, want: 7.5},
+		{name: "two values", values: []int{7, 14}, want: 10.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := median(tt.values)
+			if got != tt.want {
+				t.Errorf("median() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaysBetween(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want int
+	}{
+		{name: "same day", a: "2026-03-01T08:00:00Z", b: "2026-03-01T20:00:00Z", want: 0},
+		{name: "next day within 24 hours", a: "2026-03-01T23:00:00Z", b: "2026-03-02T01:00:00Z", want: 1},
+		{name: "one week", a: "2026-03-01T20:00:00Z", b: "2026-03-08T08:00:00Z", want: 7},
+		{name: "backwards", a: "2026-03-08T08:00:00Z", b: "2026-03-01T08:00:00Z", want: -7},
+		{name: "across daylight saving time change", a: "2026-03-28T12:00:00+01:00", b: "2026-03-30T12:00:00+02:00", want: 2},
+		{name: "across end of february", a: "2028-02-28T12:00:00Z", b: "2028-03-01T12:00:00Z", want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := time.Parse(time.RFC3339, tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := time.Parse(time.RFC3339, tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := daysBetween(a, b)
+			if got != tt.want {
+				t.Errorf("daysBetween() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,476 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/craftamap/shopping-list/db"
+	"github.com/craftamap/shopping-list/events"
+)
+
+// SyncOperationInput is an operation a client made while offline. The types and payloads are the same as for the
+// commands sent over websockets. Items created offline can be referenced using the clientItemId of the CREATE_ITEM
+// payload until the client learned the id of the item.
+type SyncOperationInput struct {
+	ClientID  string          `json:"clientId"`
+	Type      string          `json:"type"`
+	Timestamp time.Time       `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+type SyncResult struct {
+	// Operations contains the result of each operation, in the order they were sent.
+	Operations []db.SyncOperation `json:"operations"`
+	// Lists contains the items of all lists touched by the operations, after applying them.
+	Lists map[string][]db.ShoppingListItem `json:"lists"`
+	// Events contains the events the client missed while offline. It is empty if ResyncRequired is true.
+	Events []json.RawMessage `json:"events"`
+	// ResyncRequired is true if the missed events are no longer in the event log, and the client needs to refetch
+	// everything.
+	ResyncRequired bool `json:"resyncRequired"`
+	// Seq is the sequence number to continue receiving events from.
+	Seq int64 `json:"seq"`
+}
+
+// ErrInvalidSync is returned if a sync request is not valid.
+var ErrInvalidSync = errors.New("invalid sync")
+
+// errSyncConflict marks operations that were not applied, as the server state changed in the meantime.
+var errSyncConflict = errors.New("conflict")
+
+type SyncService struct {
+	itemService *ItemService
+	listService *ListService
+	itemRepo    *db.ItemRepository
+	syncRepo    *db.SyncOperationRepository
+	eventRepo   *db.EventRepository
+	hub         *events.EventHub
+	tx          *txRunner
+}
+
+func NewSyncService(itemService *ItemService, listService *ListService, itemRepo *db.ItemRepository, syncRepo *db.SyncOperationRepository, eventRepo *db.EventRepository, hub *events.EventHub) *SyncService {
+	return &SyncService{
+		itemService: itemService,
+		listService: listService,
+		itemRepo:    itemRepo,
+		syncRepo:    syncRepo,
+		eventRepo:   eventRepo,
+		hub:         hub,
+		tx:          itemService.tx.withSyncRepo(syncRepo),
+	}
+}
+
+// Sync applies the operations the device made in the given order, and returns the resulting state together with the
+// events the client missed since the sequence number since.
+//
+// Operations are only applied once; sending an operation with the same client id from the same device again returns
+// the previous result. Temporary item ids are resolved among the operations of the device.
+// Server changes win: an operation on an item that no longer has the version the operation was based on, or on an
+// item or list that no longer exists, is not applied, and reported as conflict. Deleting an already deleted item is
+// not a conflict. Operations without version are applied regardless of changes on the server.
+func (ss *SyncService) Sync(ctx context.Context, deviceID string, since *int64, ops []SyncOperationInput) (SyncResult, error) {
+	if deviceID == "" {
+		return SyncResult{}, fmt.Errorf("%w: deviceId must not be empty", ErrInvalidSync)
+	}
+	for _, op := range ops {
+		if op.ClientID == "" {
+			return SyncResult{}, fmt.Errorf("%w: operation without clientId", ErrInvalidSync)
+		}
+	}
+
+	result := SyncResult{
+		Operations: []db.SyncOperation{},
+		Lists:      map[string][]db.ShoppingListItem{},
+		Events:     []json.RawMessage{},
+	}
+
+	// missed events are determined before applying the operations, so that events caused by them are not included
+	if since != nil {
+		missedEvents, latest, ok, err := ss.hub.EventsSince(ctx, *since)
+		if err != nil {
+			return SyncResult{}, fmt.Errorf("failed to get missed events: %w", err)
+		}
+		result.Seq = latest
+		result.ResyncRequired = !ok
+		if ok {
+			result.Events = missedEvents
+		}
+	} else {
+		_, latest, err := ss.eventRepo.FindSeqBounds(ctx)
+		if err != nil {
+			return SyncResult{}, fmt.Errorf("failed to get latest sequence number: %w", err)
+		}
+		result.Seq = latest
+		result.ResyncRequired = true
+	}
+
+	batch := &syncBatch{
+		deviceID:      deviceID,
+		clientItemIDs: map[string]string{},
+		versions:      map[string]int{},
+		listVersions:  map[string]int{},
+	}
+	touchedLists := map[string]bool{}
+	for _, op := range ops {
+		syncOp, listID, err := ss.apply(ctx, batch, op)
+		if err != nil {
+			return SyncResult{}, fmt.Errorf("failed to store sync operation: %w", err)
+		}
+		if listID != "" {
+			touchedLists[listID] = true
+		}
+		result.Operations = append(result.Operations, syncOp)
+	}
+
+	for listID := range touchedLists {
+		items, err := ss.itemRepo.FindAllByListId(ctx, listID)
+		if err != nil {
+			return SyncResult{}, fmt.Errorf("failed to get items of list %s: %w", listID, err)
+		}
+		result.Lists[listID] = items
+	}
+
+	return result, nil
+}
+
+// syncBatch contains the state of a single sync request.
+type syncBatch struct {
+	deviceID string
+	// clientItemIDs maps the temporary ids of items created by operations of this sync to their ids on the server
+	clientItemIDs map[string]string
+	// versions contains the versions of the items changed by operations of this sync, so that later operations on
+	// the same item do not conflict with earlier ones
+	versions map[string]int
+	// listVersions contains the versions of the lists changed by operations of this sync
+	listVersions map[string]int
+}
+
+// resolveID maps the temporary id of an item created offline by the device to the id of the item on the server. Items
+// created earlier in the same sync are resolved first; otherwise, items created by earlier syncs of the device are
+// looked up.
+func (ss *SyncService) resolveID(ctx context.Context, s *txScope, batch *syncBatch, id *string) (*string, error) {
+	if id == nil {
+		return nil, nil
+	}
+	if itemID, ok := batch.clientItemIDs[*id]; ok {
+		return &itemID, nil
+	}
+	itemID, found, err := s.syncRepo.FindItemIdByClientItemId(ctx, batch.deviceID, *id)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return &itemID, nil
+	}
+	return id, nil
+}
+
+// findItemForOperation returns the item the operation targets, and the version the item is expected to have: the
+// version it got from an earlier operation of the same sync, or else the version the operation was based on, if the
+// client sent it. It returns errSyncConflict if the item no longer exists or has a different version.
+func (ss *SyncService) findItemForOperation(ctx context.Context, s *txScope, batch *syncBatch, itemID string, version *int) (db.ShoppingListItem, *int, error) {
+	item, err := s.itemRepo.FindByID(ctx, itemID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.ShoppingListItem{}, nil, fmt.Errorf("item %s no longer exists: %w", itemID, errSyncConflict)
+	}
+	if err != nil {
+		return db.ShoppingListItem{}, nil, err
+	}
+
+	if batchVersion, ok := batch.versions[itemID]; ok {
+		version = &batchVersion
+	}
+	if version != nil && item.Version != *version {
+		return item, nil, fmt.Errorf("item %s was changed on the server: %w", itemID, errSyncConflict)
+	}
+	return item, version, nil
+}
+
+// afterChange handles the result of changing an item: version conflicts detected while changing the item are reported
+// as conflict, and the new version of the item is remembered for later operations of the same sync.
+func (ss *SyncService) afterChange(ctx context.Context, s *txScope, batch *syncBatch, itemID string, err error) error {
+	if errors.Is(err, ErrVersionConflict) {
+		return fmt.Errorf("item %s was changed on the server: %w", itemID, errSyncConflict)
+	}
+	if err != nil {
+		return err
+	}
+	item, err := s.itemRepo.FindByID(ctx, itemID)
+	if errors.Is(err, sql.ErrNoRows) {
+		delete(batch.versions, itemID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	batch.versions[itemID] = item.Version
+	return nil
+}
+
+// updateList changes a list, like findItemForOperation and afterChange do for items: the list is expected to have the
+// version it got from an earlier operation of the same sync, or else the version the operation was based on. It returns
+// the id of the list, if it exists.
+func (ss *SyncService) updateList(ctx context.Context, s *txScope, batch *syncBatch, listID string, details ListDetails) (string, error) {
+	list, err := s.listRepo.FindById(ctx, listID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("list %s no longer exists: %w", listID, errSyncConflict)
+	}
+	if err != nil {
+		return "", err
+	}
+	if batchVersion, ok := batch.listVersions[listID]; ok {
+		details.IfVersion = &batchVersion
+	}
+	if details.IfVersion != nil && list.Version != *details.IfVersion {
+		return listID, fmt.Errorf("list %s was changed on the server: %w", listID, errSyncConflict)
+	}
+	list, err = ss.listService.update(ctx, s, listID, details)
+	if err != nil {
+		return listID, err
+	}
+	batch.listVersions[listID] = list.Version
+	return listID, nil
+}
+
+// apply executes a single operation, and returns its result and the id of the list it touched. The result of an
+// applied operation is stored in the same transaction as the changes it made, so that retries and concurrent syncs
+// find either both or neither. If the operation was synced before, the stored result is returned instead.
+func (ss *SyncService) apply(ctx context.Context, batch *syncBatch, op SyncOperationInput) (db.SyncOperation, string, error) {
+	syncOp := db.SyncOperation{
+		DeviceID:        batch.deviceID,
+		ClientID:        op.ClientID,
+		Type:            op.Type,
+		ClientTimestamp: op.Timestamp.Format(time.RFC3339),
+	}
+
+	var listID string
+	var applyErr error
+	err := ss.tx.run(ctx, func(s *txScope) error {
+		existing, found, err := s.syncRepo.FindByClientId(ctx, batch.deviceID, op.ClientID)
+		if err != nil {
+			return err
+		}
+		if found {
+			syncOp = existing
+			return nil
+		}
+		listID, applyErr = ss.applyPayload(ctx, s, batch, op, &syncOp)
+		if applyErr != nil {
+			// roll back everything the operation changed before it failed
+			return applyErr
+		}
+		syncOp.Status = db.SyncOperationApplied
+		_, err = s.syncRepo.Create(ctx, syncOp)
+		return err
+	})
+	if applyErr == nil {
+		if err == nil && syncOp.ClientItemID != nil && syncOp.ItemID != nil {
+			batch.clientItemIDs[*syncOp.ClientItemID] = *syncOp.ItemID
+		}
+		return syncOp, listID, err
+	}
+
+	if errors.Is(applyErr, errSyncConflict) {
+		syncOp.Status = db.SyncOperationConflict
+	} else {
+		syncOp.Status = db.SyncOperationFailed
+	}
+	errStr := applyErr.Error()
+	syncOp.Error = &errStr
+	created, err := ss.syncRepo.Create(ctx, syncOp)
+	if err != nil {
+		return db.SyncOperation{}, "", err
+	}
+	if !created {
+		// a concurrent sync of the same operation stored its result first
+		syncOp, _, err = ss.syncRepo.FindByClientId(ctx, batch.deviceID, op.ClientID)
+		if err != nil {
+			return db.SyncOperation{}, "", err
+		}
+	}
+	return syncOp, listID, nil
+}
+
+func (ss *SyncService) applyPayload(ctx context.Context, s *txScope, batch *syncBatch, op SyncOperationInput, syncOp *db.SyncOperation) (string, error) {
+	switch op.Type {
+	case "CREATE_ITEM":
+		payload := struct {
+			ListID       string   `json:"listId"`
+			Text         string   `json:"text"`
+			Quantity     *float64 `json:"quantity"`
+			Unit         *string  `json:"unit"`
+			Category     *string  `json:"category"`
+			After        *string  `json:"after"`
+			ClientItemID *string  `json:"clientItemId"`
+		}{}
+		err := json.Unmarshal(op.Payload, &payload)
+		if err != nil {
+			return "", fmt.Errorf("invalid payload: %w", err)
+		}
+		syncOp.ClientItemID = payload.ClientItemID
+		_, err = s.listRepo.FindById(ctx, payload.ListID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("list %s no longer exists: %w", payload.ListID, errSyncConflict)
+		}
+		if err != nil {
+			return "", err
+		}
+		after, err := ss.resolveID(ctx, s, batch, payload.After)
+		if err != nil {
+			return payload.ListID, err
+		}
+		if after != nil {
+			_, err := s.itemRepo.FindByID(ctx, *after)
+			if errors.Is(err, sql.ErrNoRows) {
+				// the item to insert after was deleted in the meantime; we still want the item, so append it
+				after = nil
+			}
+		}
+		item, err := ss.itemService.create(ctx, s, payload.ListID, NewItem{
+			Text:     payload.Text,
+			Quantity: payload.Quantity,
+			Unit:     payload.Unit,
+			Category: payload.Category,
+			After:    after,
+		})
+		if err != nil {
+			return payload.ListID, err
+		}
+		syncOp.ItemID = &item.ID
+		batch.versions[item.ID] = item.Version
+		if payload.ClientItemID != nil {
+			batch.clientItemIDs[*payload.ClientItemID] = item.ID
+		}
+		return payload.ListID, nil
+	case "UPDATE_ITEM":
+		payload := struct {
+			ItemID   string   `json:"itemId"`
+			Text     *string  `json:"text"`
+			Checked  *bool    `json:"checked"`
+			Quantity *float64 `json:"quantity"`
+			Unit     *string  `json:"unit"`
+			Category *string  `json:"category"`
+			Version  *int     `json:"version"`
+		}{}
+		err := json.Unmarshal(op.Payload, &payload)
+		if err != nil {
+			return "", fmt.Errorf("invalid payload: %w", err)
+		}
+		itemID, err := ss.resolveID(ctx, s, batch, &payload.ItemID)
+		if err != nil {
+			return "", err
+		}
+		item, version, err := ss.findItemForOperation(ctx, s, batch, *itemID, payload.Version)
+		if err != nil {
+			return item.List, err
+		}
+		_, err = ss.itemService.updateById(ctx, s, item.ID, ItemChanges{
+			Text:      payload.Text,
+			Checked:   payload.Checked,
+			Quantity:  payload.Quantity,
+			Unit:      payload.Unit,
+			Category:  payload.Category,
+			IfVersion: version,
+		})
+		return item.List, ss.afterChange(ctx, s, batch, item.ID, err)
+	case "MOVE_ITEM":
+		payload := struct {
+			ItemID   string  `json:"itemId"`
+			AfterID  *string `json:"afterId"`
+			ParentID *string `json:"parentId"`
+			Version  *int    `json:"version"`
+		}{}
+		err := json.Unmarshal(op.Payload, &payload)
+		if err != nil {
+			return "", fmt.Errorf("invalid payload: %w", err)
+		}
+		itemID, err := ss.resolveID(ctx, s, batch, &payload.ItemID)
+		if err != nil {
+			return "", err
+		}
+		item, version, err := ss.findItemForOperation(ctx, s, batch, *itemID, payload.Version)
+		if err != nil {
+			return item.List, err
+		}
+		afterID, err := ss.resolveID(ctx, s, batch, payload.AfterID)
+		if err != nil {
+			return item.List, err
+		}
+		parentID, err := ss.resolveID(ctx, s, batch, payload.ParentID)
+		if err != nil {
+			return item.List, err
+		}
+		err = ss.itemService.moveById(ctx, s, item.ID, MoveInstructions{
+			AfterId:   afterID,
+			ParentId:  parentID,
+			IfVersion: version,
+		})
+		return item.List, ss.afterChange(ctx, s, batch, item.ID, err)
+	case "DELETE_ITEM":
+		payload := struct {
+			ItemID  string `json:"itemId"`
+			Version *int   `json:"version"`
+		}{}
+		err := json.Unmarshal(op.Payload, &payload)
+		if err != nil {
+			return "", fmt.Errorf("invalid payload: %w", err)
+		}
+		itemID, err := ss.resolveID(ctx, s, batch, &payload.ItemID)
+		if err != nil {
+			return "", err
+		}
+		_, err = s.itemRepo.FindByID(ctx, *itemID)
+		if errors.Is(err, sql.ErrNoRows) {
+			// already deleted, nothing to do
+			return "", nil
+		}
+		item, version, err := ss.findItemForOperation(ctx, s, batch, *itemID, payload.Version)
+		if err != nil {
+			return item.List, err
+		}
+		err = ss.itemService.deleteById(ctx, s, item.ID, version)
+		return item.List, ss.afterChange(ctx, s, batch, item.ID, err)
+	case "UPDATE_LIST_STATUS":
+		payload := struct {
+			ListID  string `json:"listId"`
+			Status  string `json:"status"`
+			Version *int   `json:"version"`
+		}{}
+		err := json.Unmarshal(op.Payload, &payload)
+		if err != nil {
+			return "", fmt.Errorf("invalid payload: %w", err)
+		}
+		return ss.updateList(ctx, s, batch, payload.ListID, ListDetails{
+			Status:    &payload.Status,
+			IfVersion: payload.Version,
+		})
+	case "UPDATE_LIST":
+		payload := struct {
+			ListID      string  `json:"listId"`
+			Status      *string `json:"status"`
+			Title       *string `json:"title"`
+			Notes       *string `json:"notes"`
+			PlannedDate *string `json:"plannedDate"`
+			Store       *string `json:"store"`
+			Version     *int    `json:"version"`
+		}{}
+		err := json.Unmarshal(op.Payload, &payload)
+		if err != nil {
+			return "", fmt.Errorf("invalid payload: %w", err)
+		}
+		return ss.updateList(ctx, s, batch, payload.ListID, ListDetails{
+			Status:      payload.Status,
+			Title:       payload.Title,
+			Notes:       payload.Notes,
+			PlannedDate: payload.PlannedDate,
+			Store:       payload.Store,
+			IfVersion:   payload.Version,
+		})
+	default:
+		return "", fmt.Errorf("unknown operation %s", op.Type)
+	}
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/craftamap/shopping-list/db"
+)
+
+// ErrInvalidTemplate is returned if a template to be saved is not valid.
+var ErrInvalidTemplate = errors.New("invalid template")
+
+type TemplateService struct {
+	dbConn       *sql.DB
+	templateRepo *db.TemplateRepository
+	listRepo     *db.ListRepository
+	itemRepo     *db.ItemRepository
+	categoryRepo *db.CategoryRepository
+}
+
+func NewTemplateService(dbConn *sql.DB, templateRepo *db.TemplateRepository, listRepo *db.ListRepository, itemRepo *db.ItemRepository, categoryRepo *db.CategoryRepository) *TemplateService {
+	return &TemplateService{
+		dbConn:       dbConn,
+		templateRepo: templateRepo,
+		listRepo:     listRepo,
+		itemRepo:     itemRepo,
+		categoryRepo: categoryRepo,
+	}
+}
+
+func (ts *TemplateService) GetAll(ctx context.Context) ([]db.Template, error) {
+	templates, err := ts.templateRepo.FindAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting templates: %w", err)
+	}
+	return templates, nil
+}
+
+func (ts *TemplateService) FindById(ctx context.Context, templateId string) (db.Template, error) {
+	template, err := ts.templateRepo.FindById(ctx, templateId)
+	if err != nil {
+		return db.Template{}, fmt.Errorf("Error getting template: %w", err)
+	}
+	return template, nil
+}
+
+// validateTemplate checks that the template has an unique name, and normalises its items the same way items of lists
+// are: quantities are parsed from the text, and units are normalised. Categories of the items have to exist.
+func validateTemplate(ctx context.Context, templateRepo *db.TemplateRepository, categoryRepo *db.CategoryRepository, templateId string, name string, items []db.TemplateItem) ([]db.TemplateItem, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("%w: name must not be empty", ErrInvalidTemplate)
+	}
+	templates, err := templateRepo.FindAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get templates: %w", err)
+	}
+	if slices.ContainsFunc(templates, func(t db.Template) bool { return t.ID != templateId && t.Name == strings.TrimSpace(name) }) {
+		return nil, fmt.Errorf("%w: a template with this name already exists", ErrInvalidTemplate)
+	}
+	return normalizeTemplateItems(ctx, categoryRepo, items)
+}
+
+func normalizeTemplateItems(ctx context.Context, categoryRepo *db.CategoryRepository, items []db.TemplateItem) ([]db.TemplateItem, error) {
+	normalized := make([]db.TemplateItem, 0, len(items))
+	for _, item := range items {
+		item.Text = strings.TrimSpace(item.Text)
+		item.Unit = normalizeUnitInput(item.Unit)
+		if item.Quantity == nil {
+			item.Text, item.Quantity, item.Unit = ParseQuantity(item.Text)
+		}
+		if item.Text == "" {
+			return nil, fmt.Errorf("%w: text of items must not be empty", ErrInvalidTemplate)
+		}
+		if item.Quantity != nil && *item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: quantity must be positive", ErrInvalidTemplate)
+		}
+		item.Note = emptyToNil(item.Note)
+		item.Category = emptyToNil(item.Category)
+		if item.Category != nil {
+			_, err := categoryRepo.FindById(ctx, *item.Category)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("%w: unknown category %s", ErrInvalidTemplate, *item.Category)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to get category: %w", err)
+			}
+		}
+
+		children, err := normalizeTemplateItems(ctx, categoryRepo, item.Children)
+		if err != nil {
+			return nil, err
+		}
+		item.Children = children
+		normalized = append(normalized, item)
+	}
+	return normalized, nil
+}
+
+// Create creates a template with the given tree of items.
+func (ts *TemplateService) Create(ctx context.Context, name string, items []db.TemplateItem) (db.Template, error) {
+	var template db.Template
+	err := db.RunInTx(ctx, ts.dbConn, func(tx *sql.Tx) error {
+		templateRepo := ts.templateRepo.WithTx(tx)
+		items, err := validateTemplate(ctx, templateRepo, ts.categoryRepo.WithTx(tx), "", name, items)
+		if err != nil {
+			return err
+		}
+		id, err := templateRepo.Create(ctx, strings.TrimSpace(name), items)
+		if err != nil {
+			return err
+		}
+		template, err = templateRepo.FindById(ctx, id)
+		return err
+	})
+	return template, err
+}
+
+// CreateFromList creates a template containing the items of the list, keeping their nesting and their order. Whether
+// the items were checked is not taken over.
+func (ts *TemplateService) CreateFromList(ctx context.Context, listId string, name string) (db.Template, error) {
+	var template db.Template
+	err := db.RunInTx(ctx, ts.dbConn, func(tx *sql.Tx) error {
+		_, err := ts.listRepo.WithTx(tx).FindById(ctx, listId)
+		if err != nil {
+			return fmt.Errorf("Failed to get list to be saved as template: %w", err)
+		}
+		listItems, err := ts.itemRepo.WithTx(tx).FindAllByListId(ctx, listId)
+		if err != nil {
+			return fmt.Errorf("Failed to get items to be saved as template: %w", err)
+		}
+
+		templateRepo := ts.templateRepo.WithTx(tx)
+		items, err := validateTemplate(ctx, templateRepo, ts.categoryRepo.WithTx(tx), "", name, templateItemsOfList(listItems))
+		if err != nil {
+			return err
+		}
+		id, err := templateRepo.Create(ctx, strings.TrimSpace(name), items)
+		if err != nil {
+			return err
+		}
+		template, err = templateRepo.FindById(ctx, id)
+		return err
+	})
+	return template, err
+}
+
+// Update replaces the name and the items of the template.
+func (ts *TemplateService) Update(ctx context.Context, templateId string, name string, items []db.TemplateItem) (db.Template, error) {
+	var template db.Template
+	err := db.RunInTx(ctx, ts.dbConn, func(tx *sql.Tx) error {
+		templateRepo := ts.templateRepo.WithTx(tx)
+		_, err := templateRepo.FindById(ctx, templateId)
+		if err != nil {
+			return fmt.Errorf("Failed to get template to be updated: %w", err)
+		}
+		items, err := validateTemplate(ctx, templateRepo, ts.categoryRepo.WithTx(tx), templateId, name, items)
+		if err != nil {
+			return err
+		}
+		err = templateRepo.Update(ctx, templateId, strings.TrimSpace(name), items)
+		if err != nil {
+			return err
+		}
+		template, err = templateRepo.FindById(ctx, templateId)
+		return err
+	})
+	return template, err
+}
+
+func (ts *TemplateService) Delete(ctx context.Context, templateId string) error {
+	return ts.templateRepo.Delete(ctx, templateId)
+}
+
+// templateItemsOfList builds the tree of template items from the items of a list, which are ordered by sort.
+func templateItemsOfList(items []db.ShoppingListItem) []db.TemplateItem {
+	childrenByParent := map[string][]db.ShoppingListItem{}
+	for _, item := range items {
+		// top level items are stored with the empty string as key
+		key := ""
+		if item.Parent != nil {
+			key = *item.Parent
+		}
+		childrenByParent[key] = append(childrenByParent[key], item)
+	}
+
+	var build func(parent string) []db.TemplateItem
+	build = func(parent string) []db.TemplateItem {
+		templateItems := []db.TemplateItem{}
+		for _, item := range childrenByParent[parent] {
+			templateItems = append(templateItems, db.TemplateItem{
+				Text:     item.Text,
+				Quantity: item.Quantity,
+				Unit:     item.Unit,
+				Note:     item.Note,
+				Category: item.Category,
+				Children: build(item.ID),
+			})
+		}
+		return templateItems
+	}
+	return build("")
+}
+
+// listItemsOfTemplates flattens the item trees of the templates into unchecked items of a list, which can be copied
+// into a list using copyItems. The items of each template follow the items of the previous template.
+func listItemsOfTemplates(templates []db.Template) []db.ShoppingListItem {
+	items := []db.ShoppingListItem{}
+	var flatten func(templateItems []db.TemplateItem, parent *string, firstPosition int)
+	flatten = func(templateItems []db.TemplateItem, parent *string, firstPosition int) {
+		for i, templateItem := range templateItems {
+			position := firstPosition + i
+			items = append(items, db.ShoppingListItem{
+				ID:            templateItem.ID,
+				Text:          templateItem.Text,
+				Quantity:      templateItem.Quantity,
+				Unit:          templateItem.Unit,
+				Note:          templateItem.Note,
+				Category:      templateItem.Category,
+				Parent:        parent,
+				Sort:          float64(position),
+				SortFractions: []int{position, 1},
+			})
+			flatten(templateItem.Children, &templateItem.ID, 1)
+		}
+	}
+
+	position := 1
+	for _, template := range templates {
+		flatten(template.Items, nil, position)
+		position += len(template.Items)
+	}
+	return items
+}
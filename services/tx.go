@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"github.com/craftamap/shopping-list/db"
+	"github.com/craftamap/shopping-list/events"
+)
+
+// txScope holds the repositories of a single service operation, bound to the transaction of that operation, and the
+// events the operation wants to publish.
+type txScope struct {
+	listRepo     *db.ListRepository
+	itemRepo     *db.ItemRepository
+	categoryRepo *db.CategoryRepository
+	historyRepo  *db.ItemHistoryRepository
+	// syncRepo is only set for runners created by withSyncRepo
+	syncRepo *db.SyncOperationRepository
+	events   []events.Event
+}
+
+// publish queues the events; they are published once the transaction was committed.
+func (s *txScope) publish(e ...events.Event) {
+	s.events = append(s.events, e...)
+}
+
+// publishItemEvent queues the given item event, followed by an ItemsInListChangedEvent for older clients that only
+// know how to refetch the whole list.
+func (s *txScope) publishItemEvent(event events.ItemEvent) {
+	s.publish(event, events.NewItemsInListChangedEvent(event.ListID))
+}
+
+type txRunner struct {
+	dbConn       *sql.DB
+	listRepo     *db.ListRepository
+	itemRepo     *db.ItemRepository
+	categoryRepo *db.CategoryRepository
+	historyRepo  *db.ItemHistoryRepository
+	syncRepo     *db.SyncOperationRepository
+	eventBus     events.EventBus
+}
+
+// withSyncRepo returns a copy of the runner whose scopes also contain the sync operation repository, so that sync
+// operations can be recorded in the same transaction as the changes they make.
+func (r *txRunner) withSyncRepo(syncRepo *db.SyncOperationRepository) *txRunner {
+	runner := *r
+	runner.syncRepo = syncRepo
+	return &runner
+}
+
+// run runs fn in a transaction. The events queued by fn are published after the transaction was committed, and
+// dropped if it was rolled back. Events are published synchronously, so that clients receive them in the order the
+// changes happened.
+func (r *txRunner) run(ctx context.Context, fn func(s *txScope) error) error {
+	s := &txScope{}
+	err := db.RunInTx(ctx, r.dbConn, func(tx *sql.Tx) error {
+		s.listRepo = r.listRepo.WithTx(tx)
+		s.itemRepo = r.itemRepo.WithTx(tx)
+		s.categoryRepo = r.categoryRepo.WithTx(tx)
+		s.historyRepo = r.historyRepo.WithTx(tx)
+		if r.syncRepo != nil {
+			s.syncRepo = r.syncRepo.WithTx(tx)
+		}
+		return fn(s)
+	})
+	if err != nil {
+		return err
+	}
+	for _, e := range s.events {
+		err := r.eventBus.Publish(e)
+		if err != nil {
+			slog.Error("error during publish", "err", err)
+		}
+	}
+	return nil
+}
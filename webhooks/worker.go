@@ -0,0 +1,233 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/craftamap/shopping-list/db"
+	"github.com/craftamap/shopping-list/events"
+)
+
+const maxAttempts = 5
+const initialBackoff = 5 * time.Second
+const requestTimeout = 10 * time.Second
+
+// cursorName is the name of the event cursor that stores the last event the worker processed.
+const cursorName = "webhooks"
+
+const HeaderEvent = "X-Shopping-List-Event"
+const HeaderDelivery = "X-Shopping-List-Delivery"
+const HeaderSignature = "X-Shopping-List-Signature"
+
+// Worker delivers the events published on the EventHub to the registered webhooks.
+type Worker struct {
+	hub         *events.EventHub
+	eventRepo   *db.EventRepository
+	webhookRepo *db.WebhookRepository
+	client      *http.Client
+}
+
+func NewWorker(hub *events.EventHub, eventRepo *db.EventRepository, webhookRepo *db.WebhookRepository) *Worker {
+	return &Worker{
+		hub:         hub,
+		eventRepo:   eventRepo,
+		webhookRepo: webhookRepo,
+		client: &http.Client{
+			Timeout: requestTimeout,
+		},
+	}
+}
+
+// GenerateSecret creates a random secret used to sign the payloads sent to a webhook.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign computes the signature of a payload, which is sent in the X-Shopping-List-Signature header. Receivers can
+// verify it by computing the HMAC-SHA256 of the request body using the secret of the webhook.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run delivers events until ctx is done. Deliveries still being retried are cancelled when ctx is done.
+// The worker resumes after the last event it processed before being stopped, and continues deliveries that were not
+// finished yet.
+func (w *Worker) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var since *int64
+	lastSeq, ok, err := w.eventRepo.FindCursor(ctx, cursorName)
+	if err != nil {
+		return err
+	}
+	if ok {
+		since = &lastSeq
+	}
+
+	err = w.resumeDeliveries(ctx, &wg)
+	if err != nil {
+		return err
+	}
+
+	return w.hub.Stream(ctx, since, func(event events.StreamedEvent) error {
+		webhooks, err := w.webhookRepo.FindAll(ctx)
+		if err != nil {
+			slog.Error("failed to find webhooks, skipping event", "seq", event.Seq, "err", err)
+			return nil
+		}
+		for _, webhook := range webhooks {
+			if len(webhook.EventTypes) > 0 && !slices.Contains(webhook.EventTypes, string(event.Type)) {
+				continue
+			}
+			w.startDelivery(ctx, &wg, webhook, event, 1)
+		}
+		// the first attempts are claimed already, so the deliveries are resumed if the worker is stopped now
+		err = w.eventRepo.SaveCursor(ctx, cursorName, event.Seq)
+		if err != nil {
+			slog.Error("failed to save last processed event", "seq", event.Seq, "err", err)
+		}
+		return nil
+	})
+}
+
+// resumeDeliveries continues the deliveries that were not finished when the worker was stopped, as long as their
+// event is still in the event log.
+func (w *Worker) resumeDeliveries(ctx context.Context, wg *sync.WaitGroup) error {
+	// attempts pending for longer than a request can take were interrupted
+	deliveries, err := w.webhookRepo.FindUnfinishedDeliveries(ctx, maxAttempts, time.Now().Add(-2*requestTimeout))
+	if err != nil {
+		return err
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	webhooks, err := w.webhookRepo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	webhooksByID := map[string]db.Webhook{}
+	for _, webhook := range webhooks {
+		webhooksByID[webhook.ID] = webhook
+	}
+
+	for _, delivery := range deliveries {
+		webhook, ok := webhooksByID[delivery.Webhook]
+		if !ok || delivery.Attempt >= maxAttempts {
+			continue
+		}
+		event, ok, err := w.hub.FindEvent(ctx, delivery.EventSeq)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			slog.Warn("event of unfinished webhook delivery is no longer in event log", "webhook", delivery.Webhook, "seq", delivery.EventSeq)
+			continue
+		}
+		w.startDelivery(ctx, wg, webhook, event, delivery.Attempt+1)
+	}
+	return nil
+}
+
+// startDelivery claims the attempt to deliver the event to the webhook, and delivers it in the background.
+func (w *Worker) startDelivery(ctx context.Context, wg *sync.WaitGroup, webhook db.Webhook, event events.StreamedEvent, attempt int) {
+	deliveryID, claimed, err := w.webhookRepo.ClaimDelivery(ctx, webhook.ID, event.Seq, attempt)
+	if err != nil {
+		slog.Error("failed to claim webhook delivery", "webhook", webhook.ID, "seq", event.Seq, "err", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.deliver(ctx, webhook, event, attempt, deliveryID)
+	}()
+}
+
+// deliver sends the event to the webhook, starting with the given attempt, which must already be claimed, and retrying
+// with exponential backoff. Every attempt is recorded in the delivery log. If an attempt was already claimed by another
+// server instance, deliver stops.
+func (w *Worker) deliver(ctx context.Context, webhook db.Webhook, event events.StreamedEvent, attempt int, deliveryID int) {
+	backoff := initialBackoff << (attempt - 1)
+	for {
+		responseStatus, err := w.send(ctx, webhook, event)
+		status := db.WebhookDeliverySucceeded
+		var deliveryErr *string
+		if err != nil {
+			status = db.WebhookDeliveryFailed
+			errStr := err.Error()
+			deliveryErr = &errStr
+		}
+		finishErr := w.webhookRepo.FinishDelivery(ctx, deliveryID, status, responseStatus, deliveryErr)
+		if finishErr != nil {
+			slog.Error("failed to record webhook delivery", "webhook", webhook.ID, "seq", event.Seq, "err", finishErr)
+		}
+		if err == nil {
+			return
+		}
+		slog.Warn("failed to deliver webhook", "webhook", webhook.ID, "seq", event.Seq, "attempt", attempt, "err", err)
+		if attempt >= maxAttempts {
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = backoff * 2
+		attempt++
+
+		var claimed bool
+		deliveryID, claimed, err = w.webhookRepo.ClaimDelivery(ctx, webhook.ID, event.Seq, attempt)
+		if err != nil {
+			slog.Error("failed to claim webhook delivery", "webhook", webhook.ID, "seq", event.Seq, "err", err)
+			return
+		}
+		if !claimed {
+			return
+		}
+	}
+}
+
+func (w *Worker) send(ctx context.Context, webhook db.Webhook, event events.StreamedEvent) (*int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(event.Data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(event.Type))
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(event.Seq, 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, event.Data))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return &resp.StatusCode, nil
+}